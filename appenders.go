@@ -54,3 +54,182 @@ func getTableAlias(dialect schema.Dialect, of any) (string, error) {
 		return "", fmt.Errorf("could not find table for %v", of)
 	}
 }
+
+type ColumnRef struct {
+	model any
+	field string
+}
+
+var _ schema.QueryAppender = (*ColumnRef)(nil)
+
+// Column references the column of a model field by its Go name. It panics when the
+// model has no such field, so references declared as package variables fail at startup.
+func Column(model any, field string) *ColumnRef {
+	if err := checkColumnField(model, field); err != nil {
+		panic(err)
+	}
+	return &ColumnRef{model: model, field: field}
+}
+
+func checkColumnField(model any, field string) error {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("could not find table for %v", model)
+	}
+	tbl := modelTables.Get(t)
+	for _, f := range tbl.Fields {
+		if f.GoName == field {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s does not have column for field %s", tbl.TypeName, field)
+}
+
+func (c *ColumnRef) resolve(dialect schema.Dialect) (*schema.Table, *schema.Field, error) {
+	tbl := dialect.Tables().Get(reflect.TypeOf(c.model))
+	if tbl == nil {
+		return nil, nil, fmt.Errorf("could not find table for %v", c.model)
+	}
+	for _, field := range tbl.Fields {
+		if field.GoName == c.field {
+			return tbl, field, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s does not have column for field %s", tbl.TypeName, c.field)
+}
+
+// AppendQuery appends the column qualified with the model's table alias.
+func (c *ColumnRef) AppendQuery(fmter schema.QueryGen, b []byte) ([]byte, error) {
+	if tbl, field, err := c.resolve(fmter.Dialect()); err != nil {
+		return nil, err
+	} else {
+		b = append(b, tbl.SQLAlias...)
+		b = append(b, '.')
+		return append(b, field.SQLName...), nil
+	}
+}
+
+// Name appends the unqualified column name.
+func (c *ColumnRef) Name() QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		if _, field, err := c.resolve(fmter.Dialect()); err != nil {
+			return nil, err
+		} else {
+			return append(b, field.SQLName...), nil
+		}
+	}
+}
+
+func appendOperand(fmter schema.QueryGen, b []byte, v any) ([]byte, error) {
+	if app, ok := v.(schema.QueryAppender); ok {
+		return app.AppendQuery(fmter, b)
+	}
+	return fmter.AppendQuery(b, "?", v), nil
+}
+
+func appendOperands(fmter schema.QueryGen, b []byte, sep string, vs []any) ([]byte, error) {
+	var err error
+	for i, v := range vs {
+		if i > 0 {
+			b = append(b, sep...)
+		}
+		if b, err = appendOperand(fmter, b, v); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func Eq(left, right any) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		return appendOperands(fmter, b, " = ", []any{left, right})
+	}
+}
+
+// In matches the column against values, a single slice value is expanded.
+func In(col any, values ...any) QueryAppenderFunc {
+	if len(values) == 1 {
+		if v := reflect.ValueOf(values[0]); v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+			values = make([]any, v.Len())
+			for i := range values {
+				values[i] = v.Index(i).Interface()
+			}
+		}
+	}
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		if len(values) == 0 {
+			return append(b, "1 = 0"...), nil
+		}
+		b, err := appendOperand(fmter, b, col)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, " IN ("...)
+		if b, err = appendOperands(fmter, b, ", ", values); err != nil {
+			return nil, err
+		}
+		return append(b, ')'), nil
+	}
+}
+
+func Between(col, low, high any) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		b, err := appendOperand(fmter, b, col)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, " BETWEEN "...)
+		return appendOperands(fmter, b, " AND ", []any{low, high})
+	}
+}
+
+func IsNull(col any) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		b, err := appendOperand(fmter, b, col)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, " IS NULL"...), nil
+	}
+}
+
+func And(exprs ...schema.QueryAppender) QueryAppenderFunc {
+	return appendGroup(" AND ", "1 = 1", exprs)
+}
+
+func Or(exprs ...schema.QueryAppender) QueryAppenderFunc {
+	return appendGroup(" OR ", "1 = 0", exprs)
+}
+
+func appendGroup(sep string, empty string, exprs []schema.QueryAppender) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		if len(exprs) == 0 {
+			return append(b, empty...), nil
+		}
+		b = append(b, '(')
+		for i, expr := range exprs {
+			if i > 0 {
+				b = append(b, sep...)
+			}
+			var err error
+			if b, err = expr.AppendQuery(fmter, b); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ')'), nil
+	}
+}
+
+func Not(expr schema.QueryAppender) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		b = append(b, "NOT ("...)
+		b, err := expr.AppendQuery(fmter, b)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, ')'), nil
+	}
+}
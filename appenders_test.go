@@ -0,0 +1,88 @@
+package bunquery_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/mmorton/bunquery"
+)
+
+type exprBook struct {
+	bun.BaseModel `bun:"table:books,alias:b"`
+	ID            int64 `bun:",pk"`
+	AuthorID      int64 `bun:"writer_id"`
+	Title         string
+}
+
+func TestExpressions(t *testing.T) {
+	id := bunquery.Column((*exprBook)(nil), "ID")
+	author := bunquery.Column((*exprBook)(nil), "AuthorID")
+	title := bunquery.Column((*exprBook)(nil), "Title")
+
+	var tests = []struct {
+		expr schema.QueryAppender
+		want string
+	}{
+		{author, `"b"."writer_id"`},
+		{author.Name(), `"writer_id"`},
+		{bunquery.Eq(author, 5), `"b"."writer_id" = ?`},
+		{bunquery.In(id, []int64{1, 2}), `"b"."id" IN (?, ?)`},
+		{bunquery.In(id), `1 = 0`},
+		{bunquery.Between(title, "a", "m"), `"b"."title" BETWEEN ? AND ?`},
+		{bunquery.Not(bunquery.IsNull(title)), `NOT ("b"."title" IS NULL)`},
+		{bunquery.And(bunquery.Eq(id, 1), bunquery.Or(bunquery.Eq(id, 2), bunquery.Eq(id, 3))), `("b"."id" = ? AND ("b"."id" = ? OR "b"."id" = ?))`},
+		{bunquery.And(), `1 = 1`},
+		{bunquery.Or(), `1 = 0`},
+	}
+
+	// The nop dialect leaves values as placeholders.
+	for _, tt := range tests {
+		b, err := tt.expr.AppendQuery(schema.NewNopQueryGen(), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, string(b))
+	}
+
+	assert.Panics(t, func() { bunquery.Column((*exprBook)(nil), "Missing") })
+}
+
+type columnAuthor struct {
+	bun.BaseModel `bun:"table:column_authors"`
+	ID            int64 `bun:",pk"`
+	Name          string
+	Ignored       string `bun:"-"`
+	secret        string
+	Books         []*columnBook `bun:"rel:has-many,join:id=author_id"`
+	Tags          []columnTag   `bun:"m2m:column_author_tags,join:Author=Tag"`
+	Editor        *columnAuthor `bun:"rel:belongs-to,join:editor_id=id"`
+	EditorID      int64
+}
+
+type columnBook struct {
+	ID       int64 `bun:",pk"`
+	AuthorID int64
+}
+
+type columnTag struct {
+	ID int64 `bun:",pk"`
+}
+
+type columnAuthorTag struct {
+	bun.BaseModel `bun:"table:column_author_tags"`
+	AuthorID      int64         `bun:",pk"`
+	Author        *columnAuthor `bun:"rel:belongs-to,join:author_id=id"`
+	TagID         int64         `bun:",pk"`
+	Tag           *columnTag    `bun:"rel:belongs-to,join:tag_id=id"`
+}
+
+func TestColumnFields(t *testing.T) {
+	bunquery.RegisterModel((*columnAuthorTag)(nil))
+	for _, field := range []string{"ID", "Name", "EditorID"} {
+		assert.NotPanics(t, func() { bunquery.Column((*columnAuthor)(nil), field) }, field)
+	}
+	for _, field := range []string{"BaseModel", "Ignored", "secret", "Books", "Tags", "Editor", "Missing"} {
+		assert.Panics(t, func() { bunquery.Column((*columnAuthor)(nil), field) }, field)
+	}
+}
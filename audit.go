@@ -0,0 +1,257 @@
+package bunquery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+type AuditRecord struct {
+	bun.BaseModel `bun:"table:audit_log"`
+
+	ID         int64 `bun:",pk,autoincrement"`
+	Actor      string
+	TableName  string
+	PrimaryKey json.RawMessage `bun:"type:json"`
+	Operation  string
+	Before     json.RawMessage `bun:"type:json,nullzero"`
+	After      json.RawMessage `bun:"type:json,nullzero"`
+	CreatedAt  time.Time       `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+type AuditOptions struct {
+	Table string
+	Actor func(ctx context.Context) (string, error)
+}
+
+type AuditOption = func(*AuditOptions)
+
+func WithAuditTable(table string) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.Table = table
+	}
+}
+
+func WithAuditActor(actor func(ctx context.Context) (string, error)) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.Actor = actor
+	}
+}
+
+// AuditMod records every insert, update and delete in the audit table, in the same
+// transaction. Rows are read before and after the statement, so the records hold what
+// was written, and updates and deletes by filter are recorded per row.
+type AuditMod struct {
+	opts *AuditOptions
+}
+
+var _ QueryMod = (*AuditMod)(nil)
+var _ MutationHook = (*AuditMod)(nil)
+
+func NewAuditMod(opts ...AuditOption) *AuditMod {
+	res := &AuditOptions{}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return &AuditMod{opts: res}
+}
+
+func (m *AuditMod) Kind() string { return "audit" }
+
+func (m *AuditMod) Bind(ctx context.Context, db bun.IDB, qry QueryBuilderEx, args ...any) {}
+
+type auditCtxKey struct{}
+
+// auditRow is a row as it was before the statement, nil when it did not exist.
+type auditRow struct {
+	pk     map[string]any
+	values map[string]any
+}
+
+func (m *AuditMod) BeforeMutation(ctx context.Context, db bun.IDB, event *MutationEvent) (context.Context, error) {
+	if event.Table == nil || len(event.Table.PKs) == 0 || event.Kind() == QueryInsert {
+		return ctx, nil
+	}
+
+	// Capture the rows as they are before the statement changes them. Statements by the
+	// primary keys of their models change those rows, others the rows their filter matches.
+	var before []auditRow
+	whereFields, _ := getQueryField[[]*schema.Field](event.Query, "whereFields")
+	isSlice := reflect.Indirect(reflect.ValueOf(event.Query.GetModel().Value())).Kind() == reflect.Slice
+	if isSlice || len(whereFields) > 0 && slices.Equal(whereFields, event.Table.PKs) {
+		for _, strct := range event.Models() {
+			before = append(before, auditRow{pk: auditPK(event.Table, strct)})
+		}
+		values, err := selectAuditRows(ctx, db, event.Table, before)
+		if err != nil {
+			return ctx, err
+		}
+		for i := range before {
+			before[i].values = values[i]
+		}
+	} else {
+		rows := reflect.New(reflect.SliceOf(reflect.PointerTo(event.Table.Type)))
+		qry := db.NewSelect().Model(rows.Interface())
+		if !copyQueryWhere(qry, event.Query) {
+			return ctx, fmt.Errorf("audit: can't determine the rows changed by the %s of %s", event.Kind(), event.Table.TypeName)
+		}
+		if err := qry.Scan(ctx); err != nil {
+			return ctx, err
+		}
+		for i := range rows.Elem().Len() {
+			strct := rows.Elem().Index(i).Elem()
+			before = append(before, auditRow{pk: auditPK(event.Table, strct), values: auditValues(event.Table, strct)})
+		}
+	}
+
+	return context.WithValue(ctx, auditCtxKey{}, before), nil
+}
+
+func (m *AuditMod) AfterMutation(ctx context.Context, db bun.IDB, event *MutationEvent, res sql.Result) error {
+	actor := ""
+	if m.opts.Actor != nil {
+		var err error
+		if actor, err = m.opts.Actor(ctx); err != nil {
+			return err
+		}
+	}
+
+	op := event.Kind()
+	name := event.Query.GetTableName()
+	if event.Table == nil {
+		// Statements without a model still leave a trace of who ran them.
+		return m.insert(ctx, db, []*AuditRecord{{Actor: actor, TableName: name, Operation: string(op)}})
+	}
+
+	rows, _ := ctx.Value(auditCtxKey{}).([]auditRow)
+	if op == QueryInsert {
+		for _, strct := range event.Models() {
+			rows = append(rows, auditRow{pk: auditPK(event.Table, strct)})
+		}
+	}
+
+	// Read the rows back, so the records hold what was written.
+	after := make([]map[string]any, len(rows))
+	if op != QueryDelete {
+		var err error
+		if after, err = selectAuditRows(ctx, db, event.Table, rows); err != nil {
+			return err
+		}
+	}
+
+	var records []*AuditRecord
+	for i, row := range rows {
+		prev, next := row.values, after[i]
+		if op == QueryUpdate && event.Changes != nil {
+			prev, next = auditSubset(prev, event.Changes), auditSubset(next, event.Changes)
+		}
+
+		rec := &AuditRecord{Actor: actor, TableName: name, Operation: string(op)}
+		var err error
+		if rec.PrimaryKey, err = json.Marshal(row.pk); err != nil {
+			return err
+		}
+		if rec.Before, err = auditJSON(prev); err != nil {
+			return err
+		}
+		if rec.After, err = auditJSON(next); err != nil {
+			return err
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return m.insert(ctx, db, records)
+}
+
+func (m *AuditMod) insert(ctx context.Context, db bun.IDB, records []*AuditRecord) error {
+	qry := db.NewInsert().Model(&records)
+	if m.opts.Table != "" {
+		qry = qry.ModelTableExpr("?", bun.Ident(m.opts.Table))
+	}
+	_, err := qry.Exec(ctx)
+	return err
+}
+
+func auditPK(table *schema.Table, strct reflect.Value) map[string]any {
+	pk := make(map[string]any, len(table.PKs))
+	for _, field := range table.PKs {
+		pk[field.Name] = field.Value(strct).Interface()
+	}
+	return pk
+}
+
+// selectAuditRows reads the rows with the primary keys of rows in one query. Rows that
+// don't exist are nil.
+func selectAuditRows(ctx context.Context, db bun.IDB, table *schema.Table, rows []auditRow) ([]map[string]any, error) {
+	res := make([]map[string]any, len(rows))
+	if len(rows) == 0 {
+		return res, nil
+	}
+
+	models := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(table.Type)), 0, len(rows))
+	for _, row := range rows {
+		strct := reflect.New(table.Type)
+		for _, field := range table.PKs {
+			field.Value(strct.Elem()).Set(reflect.ValueOf(row.pk[field.Name]))
+		}
+		models = reflect.Append(models, strct)
+	}
+	found := reflect.New(models.Type())
+	found.Elem().Set(models)
+	if err := db.NewSelect().Model(found.Interface()).WherePK().Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	byPK := make(map[string]map[string]any, found.Elem().Len())
+	for i := range found.Elem().Len() {
+		strct := found.Elem().Index(i).Elem()
+		key, err := json.Marshal(auditPK(table, strct))
+		if err != nil {
+			return nil, err
+		}
+		byPK[string(key)] = auditValues(table, strct)
+	}
+	for i, row := range rows {
+		key, err := json.Marshal(row.pk)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = byPK[string(key)]
+	}
+	return res, nil
+}
+
+func auditValues(table *schema.Table, strct reflect.Value) map[string]any {
+	res := make(map[string]any, len(table.Fields))
+	for _, field := range table.Fields {
+		res[field.Name] = field.Value(strct).Interface()
+	}
+	return res
+}
+
+func auditSubset(values map[string]any, only map[string]any) map[string]any {
+	if values == nil {
+		return nil
+	}
+	res := make(map[string]any, len(only))
+	for col := range only {
+		res[col] = values[col]
+	}
+	return res
+}
+
+func auditJSON(values map[string]any) (json.RawMessage, error) {
+	if values == nil {
+		return nil, nil
+	}
+	return json.Marshal(values)
+}
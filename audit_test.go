@@ -0,0 +1,101 @@
+package bunquery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmorton/bunquery"
+)
+
+type auditItem struct {
+	ID      int64 `bun:",pk,autoincrement"`
+	Title   string
+	Version int64
+}
+
+func TestAuditMod(t *testing.T) {
+	db := newSQLiteDB(t, (*auditItem)(nil), (*bunquery.AuditRecord)(nil))
+	ctx := bunquery.NewContext(context.Background(), db, bunquery.NewAuditMod(
+		bunquery.WithAuditActor(func(ctx context.Context) (string, error) { return "alice", nil }),
+	))
+
+	records := func() []string {
+		var res []*bunquery.AuditRecord
+		assert.NoError(t, db.NewSelect().Model(&res).Order("id").Scan(ctx))
+		var out []string
+		for _, rec := range res {
+			out = append(out, rec.Actor+" "+rec.Operation+" "+string(rec.PrimaryKey)+" "+string(rec.Before)+" "+string(rec.After))
+		}
+		_, err := db.NewDelete().Model((*bunquery.AuditRecord)(nil)).Where("1 = 1").Exec(ctx)
+		assert.NoError(t, err)
+		return out
+	}
+
+	a, b := &auditItem{Title: "a"}, &auditItem{Title: "b"}
+	assert.NoError(t, bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewInsert().Model(&[]*auditItem{a, b}).Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, []string{
+		`alice insert {"id":1}  {"id":1,"title":"a","version":0}`,
+		`alice insert {"id":2}  {"id":2,"title":"b","version":0}`,
+	}, records())
+
+	// The after state is read back, so Set clauses and column lists are audited as written.
+	assert.NoError(t, bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		a.Version = 5
+		if _, err := db.NewUpdate().Model(a).Set("title = ?", "set").WherePK().Exec(ctx); err != nil {
+			return err
+		}
+		b.Title, b.Version = "c", 5
+		_, err := db.NewUpdate().Model(b).Column("title").WherePK().Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, []string{
+		`alice update {"id":1} {"id":1,"title":"a","version":0} {"id":1,"title":"set","version":0}`,
+		`alice update {"id":2} {"id":2,"title":"b","version":0} {"id":2,"title":"c","version":0}`,
+	}, records())
+
+	// Updates and deletes by filter are audited per row.
+	assert.NoError(t, bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		if _, err := db.NewUpdate().Model((*auditItem)(nil)).Set("version = version + 1").Where("id > ?", 0).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDelete().Model((*auditItem)(nil)).Where("title = ?", "c").Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, []string{
+		`alice update {"id":1} {"id":1,"title":"set","version":0} {"id":1,"title":"set","version":1}`,
+		`alice update {"id":2} {"id":2,"title":"c","version":0} {"id":2,"title":"c","version":1}`,
+		`alice delete {"id":2} {"id":2,"title":"c","version":1} `,
+	}, records())
+
+	// A model updated by filter is audited by the rows matched, not by its zero key.
+	assert.NoError(t, bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model(&auditItem{Version: 9}).Column("version").Where("title = ?", "set").Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, []string{
+		`alice update {"id":1} {"id":1,"title":"set","version":1} {"id":1,"title":"set","version":9}`,
+	}, records())
+
+	// Patches record the fields they set.
+	title := "patched"
+	patch := &auditItemPatch{Title: &title}
+	patch.Patch = bunquery.CreatePatch(&auditItem{ID: 1}, patch)
+	assert.NoError(t, bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Apply(patch.Compile()).Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, []string{
+		`alice update {"id":1} {"title":"set"} {"title":"patched"}`,
+	}, records())
+}
+
+type auditItemPatch struct {
+	bunquery.Patch[auditItem, auditItemPatch]
+	Title   *string
+	Version *int64
+}
@@ -1,11 +1,58 @@
 package bunquery
 
-import "github.com/uptrace/bun"
+import (
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+type QueryKind string
+
+const (
+	QuerySelect QueryKind = "select"
+	QueryInsert QueryKind = "insert"
+	QueryUpdate QueryKind = "update"
+	QueryDelete QueryKind = "delete"
+	QueryMerge  QueryKind = "merge"
+)
+
+func getQueryKind(query any) QueryKind {
+	switch query.(type) {
+	case *bun.SelectQuery:
+		return QuerySelect
+	case *bun.InsertQuery:
+		return QueryInsert
+	case *bun.UpdateQuery:
+		return QueryUpdate
+	case *bun.DeleteQuery:
+		return QueryDelete
+	case *bun.MergeQuery:
+		return QueryMerge
+	default:
+		return ""
+	}
+}
+
+func getQueryTable(query bun.Query) *schema.Table {
+	if tm, ok := query.GetModel().(bun.TableModel); ok {
+		return tm.Table()
+	}
+	return nil
+}
 
 type QueryBuilderEx interface {
 	bun.QueryBuilder
 	With(name string, query bun.Query) QueryBuilderEx
 	Err(err error) QueryBuilderEx
+	Kind() QueryKind
+	// Table returns the schema of the query model, or nil while no model is set. Mods
+	// are bound before the model of a query built by a QueryDB is set, so they branch
+	// on its table with WhereTable.
+	Table() *schema.Table
+	// WhereTable adds a condition that is built from the query table when the query
+	// is generated. Mods are bound before the query model is set, so this is how they
+	// branch on the table. The table is nil for queries without a model, and an empty
+	// query string leaves the query unfiltered.
+	WhereTable(fn func(table *schema.Table) (string, []any)) QueryBuilderEx
 }
 
 type expandedQueryBuilder struct {
@@ -38,3 +85,21 @@ func (qbx expandedQueryBuilder) Err(err error) QueryBuilderEx {
 	qbx.raise(err)
 	return qbx
 }
+
+func (qbx expandedQueryBuilder) Kind() QueryKind {
+	return getQueryKind(qbx.Unwrap())
+}
+
+func (qbx expandedQueryBuilder) Table() *schema.Table {
+	return getQueryTable(qbx.QueryBuilder)
+}
+
+func (qbx expandedQueryBuilder) WhereTable(fn func(table *schema.Table) (string, []any)) QueryBuilderEx {
+	qbx.Where("?", QueryAppenderFunc(func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		if query, args := fn(qbx.Table()); query != "" {
+			return fmter.AppendQuery(b, query, args...), nil
+		}
+		return append(b, "1 = 1"...), nil
+	}))
+	return qbx
+}
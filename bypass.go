@@ -0,0 +1,57 @@
+package bunquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoBypassGuard denies bypasses in contexts without a BypassGuard.
+var ErrNoBypassGuard = errors.New("no bypass guard")
+
+// BypassGuard decides whether a context may remove a mod kind and is told about
+// every bypass that was allowed. Without a guard, no mod can be bypassed.
+type BypassGuard interface {
+	AllowBypass(ctx context.Context, kind string) error
+	Bypassed(ctx context.Context, kind string)
+}
+
+type BypassError struct {
+	Kind string
+	Err  error
+}
+
+func (e *BypassError) Error() string {
+	return fmt.Sprintf("bypass of %q mod denied: %v", e.Kind, e.Err)
+}
+
+func (e *BypassError) Unwrap() error {
+	return e.Err
+}
+
+type funcBypassGuard struct {
+	allow    func(ctx context.Context, kind string) error
+	bypassed func(ctx context.Context, kind string)
+}
+
+var _ BypassGuard = (*funcBypassGuard)(nil)
+
+func (g *funcBypassGuard) AllowBypass(ctx context.Context, kind string) error {
+	if g.allow == nil {
+		return nil
+	}
+	return g.allow(ctx, kind)
+}
+
+func (g *funcBypassGuard) Bypassed(ctx context.Context, kind string) {
+	if g.bypassed != nil {
+		g.bypassed(ctx, kind)
+	}
+}
+
+func NewBypassGuard(allow func(ctx context.Context, kind string) error, bypassed func(ctx context.Context, kind string)) BypassGuard {
+	return &funcBypassGuard{
+		allow:    allow,
+		bypassed: bypassed,
+	}
+}
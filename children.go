@@ -0,0 +1,121 @@
+package bunquery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Children is a change set for a has-many relation of a patch target. Upserted children
+// are updated, or inserted when they have no primary key or no row has it, and deleted
+// children are matched by primary key. All of them are scoped to the target through the
+// join columns of the relation, so children of another parent fail the patch. Patches
+// that carry children are applied with Patch.Exec.
+type Children[Child any] struct {
+	Upsert []*Child
+	Delete []*Child
+}
+
+func (c Children[Child]) empty() bool {
+	return len(c.Upsert) == 0 && len(c.Delete) == 0
+}
+
+func (c Children[Child]) apply(ctx context.Context, db MutationDB, rel *schema.Relation, parent reflect.Value) error {
+	// Point the children at the parent through the join columns. Children with and
+	// without a key are inserted apart, as bun leaves out autoincrement keys per query.
+	var inserts, keyed []*Child
+	for _, child := range c.Upsert {
+		strct := reflect.ValueOf(child).Elem()
+		for i, pk := range rel.JoinPKs {
+			if !assignPatchValue(pk.Value(strct), rel.BasePKs[i].Value(parent)) {
+				return fmt.Errorf("%s.%s can't hold %s", rel.JoinTable.TypeName, pk.GoName, rel.BasePKs[i].GoName)
+			}
+		}
+		if hasZeroPK(rel.JoinTable, strct) {
+			inserts = append(inserts, child)
+			continue
+		}
+
+		res, err := db.NewUpdate().Model(child).WherePK().Apply(whereParent[*bun.UpdateQuery](rel, parent)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n > 0 {
+			continue
+		}
+
+		// No row was changed: the child is new, unchanged, or of another parent.
+		if exists, err := db.NewSelect().Model(child).WherePK().Exists(ctx); err != nil {
+			return err
+		} else if !exists {
+			keyed = append(keyed, child)
+			continue
+		}
+		if exists, err := db.NewSelect().Model(child).WherePK().Apply(whereParent[*bun.SelectQuery](rel, parent)).Exists(ctx); err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("%s %v is not in %s of the patched %s", rel.JoinTable.TypeName, getPKValues(rel.JoinTable, strct), rel.Field.GoName, parent.Type().Name())
+		}
+	}
+
+	for _, inserts := range [][]*Child{keyed, inserts} {
+		if len(inserts) > 0 {
+			if _, err := db.NewInsert().Model(&inserts).Exec(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(c.Delete) > 0 {
+		deletes := c.Delete
+		res, err := db.NewDelete().Model(&deletes).WherePK().Apply(whereParent[*bun.DeleteQuery](rel, parent)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n != int64(len(deletes)) {
+			return fmt.Errorf("%d of the %d %s to delete are not in %s of the patched %s", int64(len(deletes))-n, len(deletes), rel.JoinTable.TypeName, rel.Field.GoName, parent.Type().Name())
+		}
+	}
+	return nil
+}
+
+// childChangeSet lets Patch.Exec apply a Children of any type.
+type childChangeSet interface {
+	empty() bool
+	apply(ctx context.Context, db MutationDB, rel *schema.Relation, parent reflect.Value) error
+}
+
+var childChangeSetType = reflect.TypeFor[childChangeSet]()
+
+func whereParent[Q interface{ Where(string, ...any) Q }](rel *schema.Relation, parent reflect.Value) func(Q) Q {
+	return func(q Q) Q {
+		for i, pk := range rel.JoinPKs {
+			q = q.Where("?TableAlias.? = ?", bun.Safe(pk.SQLName), rel.BasePKs[i].Value(parent).Interface())
+		}
+		return q
+	}
+}
+
+func hasZeroPK(table *schema.Table, strct reflect.Value) bool {
+	for _, pk := range table.PKs {
+		if !pk.HasZeroValue(strct) {
+			return false
+		}
+	}
+	return true
+}
+
+func getPKValues(table *schema.Table, strct reflect.Value) map[string]any {
+	res := make(map[string]any, len(table.PKs))
+	for _, pk := range table.PKs {
+		res[pk.Name] = pk.Value(strct).Interface()
+	}
+	return res
+}
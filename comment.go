@@ -0,0 +1,167 @@
+package bunquery
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type CommentOptions struct {
+	Keys map[string]func(ctx context.Context) string
+}
+
+type CommentOption = func(*CommentOptions)
+
+// WithCommentKey adds a request-scoped key, such as a request or trace ID, to the comment.
+func WithCommentKey(key string, value func(ctx context.Context) string) CommentOption {
+	return func(opts *CommentOptions) {
+		opts.Keys[key] = value
+	}
+}
+
+// CommentMod appends a sqlcommenter comment with the definition name, the applied mod
+// kinds and the configured context keys to every query built through QueryDB and
+// MutationDB. Commented queries are sent on the underlying connection directly, so
+// they do not go through a bun.ConnResolver.
+type CommentMod struct {
+	opts *CommentOptions
+}
+
+var _ QueryMod = (*CommentMod)(nil)
+
+func NewCommentMod(opts ...CommentOption) *CommentMod {
+	res := &CommentOptions{Keys: map[string]func(ctx context.Context) string{}}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return &CommentMod{opts: res}
+}
+
+func (m *CommentMod) Kind() string { return "sqlcommenter" }
+
+func (m *CommentMod) Bind(ctx context.Context, db bun.IDB, qry QueryBuilderEx, args ...any) {}
+
+func (m *CommentMod) comment(ctx context.Context, name string, mods QueryMods) string {
+	tags := make(map[string]string, len(m.opts.Keys)+2)
+	if name != "" {
+		tags["definition"] = name
+	}
+
+	var kinds []string
+	for _, mod := range mods {
+		if mod.Kind() != m.Kind() {
+			kinds = append(kinds, mod.Kind())
+		}
+	}
+	if len(kinds) > 0 {
+		slices.Sort(kinds)
+		tags["mods"] = strings.Join(kinds, ",")
+	}
+
+	for key, value := range m.opts.Keys {
+		if v := value(ctx); v != "" {
+			tags[key] = v
+		}
+	}
+
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	b.WriteString("/*")
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(escapeSQLComment(key))
+		b.WriteString("='")
+		b.WriteString(escapeSQLComment(tags[key]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	return b.String()
+}
+
+// commentDialects are the dialects whose databases accept a comment at the end of a
+// statement. Queries for other dialects are not commented.
+var commentDialects = []dialect.Name{dialect.PG, dialect.MySQL, dialect.SQLite, dialect.MSSQL}
+
+func getSQLComment(ctx context.Context, db bun.IDB, name string, mods QueryMods) string {
+	if !slices.Contains(commentDialects, db.Dialect().Name()) {
+		return ""
+	}
+	for _, mod := range mods {
+		if m, ok := mod.(*CommentMod); ok {
+			return m.comment(ctx, name, mods)
+		}
+	}
+	return ""
+}
+
+// escapeSQLComment url-encodes the value as the sqlcommenter spec requires, which also
+// encodes quotes and keeps "*/" out of the comment.
+func escapeSQLComment(s string) string {
+	return url.PathEscape(s)
+}
+
+// appendSQLComment adds the comment at the end of the statement, before a trailing
+// semicolon. Statements that already end in a comment are left alone.
+func appendSQLComment(query string, comment string) string {
+	stmt := strings.TrimRight(query, " \t\r\n;")
+	if endsInSQLComment(stmt) {
+		return query
+	}
+	return stmt + " " + comment + query[len(stmt):]
+}
+
+// endsInSQLComment reports whether the statement ends in a block comment, or its last
+// line in a line comment outside of quotes.
+func endsInSQLComment(stmt string) bool {
+	if strings.HasSuffix(stmt, "*/") {
+		return true
+	}
+	line := stmt[strings.LastIndexByte(stmt, '\n')+1:]
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case strings.HasPrefix(line[i:], "--"):
+			return true
+		}
+	}
+	return false
+}
+
+type commentConn struct {
+	bun.IConn
+	comment string
+}
+
+func (conn *commentConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return conn.IConn.ExecContext(ctx, appendSQLComment(query, conn.comment), args...)
+}
+
+func (conn *commentConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return conn.IConn.QueryContext(ctx, appendSQLComment(query, conn.comment), args...)
+}
+
+func (conn *commentConn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return conn.IConn.QueryRowContext(ctx, appendSQLComment(query, conn.comment), args...)
+}
@@ -0,0 +1,50 @@
+package bunquery_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/mmorton/bunquery"
+)
+
+type namedDialect struct {
+	schema.Dialect
+	name dialect.Name
+}
+
+func (d namedDialect) Name() dialect.Name { return d.name }
+
+func TestSQLComment(t *testing.T) {
+	ctx := context.Background()
+	db := bun.NewDB(&sql.DB{}, namedDialect{Dialect: schema.NewNopQueryGen().Dialect(), name: dialect.PG})
+	mods := bunquery.QueryMods{
+		bunquery.NewCommentMod(bunquery.WithCommentKey("route", func(ctx context.Context) string {
+			return "x*/; DROP TABLE users; /*'"
+		})),
+		nopMod("tenant"),
+	}
+
+	comment := bunquery.GetSQLComment(ctx, db, "GetUser", mods)
+	assert.Equal(t, `/*definition='GetUser',mods='tenant',route='x%2A%2F%3B%20DROP%20TABLE%20users%3B%20%2F%2A%27'*/`, comment)
+	assert.Equal(t, 1, strings.Count(comment, "*/"))
+
+	assert.Equal(t, "SELECT 1 "+comment+";", bunquery.AppendSQLComment("SELECT 1;", comment))
+	assert.Equal(t, "SELECT 1 /* hint */", bunquery.AppendSQLComment("SELECT 1 /* hint */", comment))
+	assert.Equal(t, "SELECT 1 -- hint\n", bunquery.AppendSQLComment("SELECT 1 -- hint\n", comment))
+	// Comments and dashes within the statement don't keep it from being commented.
+	for _, query := range []string{"SELECT /*+ hint */ 1", "SELECT 1 -- hint\nFROM t", "SELECT '--'", "SELECT 1 - -1"} {
+		assert.Equal(t, query+" "+comment, bunquery.AppendSQLComment(query, comment), query)
+	}
+
+	for _, name := range []dialect.Name{dialect.Invalid, dialect.Oracle} {
+		db := bun.NewDB(&sql.DB{}, namedDialect{Dialect: schema.NewNopQueryGen().Dialect(), name: name})
+		assert.Empty(t, bunquery.GetSQLComment(ctx, db, "GetUser", mods), name.String())
+	}
+}
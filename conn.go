@@ -0,0 +1,57 @@
+package bunquery
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+type supportsConn[P any] interface {
+	*P
+	bun.Query
+	Conn(db bun.IConn) *P
+}
+
+func unwrapConn(db bun.IDB) bun.IConn {
+	switch db := db.(type) {
+	case *bun.DB:
+		return db.DB
+	case bun.Tx:
+		return db.Tx
+	case bun.Conn:
+		return db.Conn
+	default:
+		return db
+	}
+}
+
+// applyQueryConn routes the query through connections that comment the statement
+// and, when state is set, run the mutation hooks found in mods.
+func applyQueryConn[Query any, Source supportsConn[Query]](ctx context.Context, db bun.IDB, name string, mods QueryMods, state *mutationState, query Source) Source {
+	conn := unwrapConn(db)
+	wrapped := false
+
+	if comment := getSQLComment(ctx, db, name, mods); comment != "" {
+		conn = &commentConn{IConn: conn, comment: comment}
+		wrapped = true
+	}
+
+	if state != nil {
+		// Every mutation gets an event, as patches use it without hooks.
+		event := &MutationEvent{Query: query}
+		state.track(query, event)
+		conn = &mutationConn{
+			IConn: conn,
+			db:    db,
+			state: state,
+			hooks: getMutationHooks(mods),
+			event: event,
+		}
+		wrapped = true
+	}
+
+	if wrapped {
+		query.Conn(conn)
+	}
+	return query
+}
@@ -3,14 +3,20 @@ package bunquery
 import (
 	"context"
 	"errors"
+	"slices"
 
 	"github.com/uptrace/bun"
 )
 
 type dbCtxKey struct{}
 type dbCtx struct {
-	db   bun.IDB
-	mods QueryMods
+	db    bun.IDB
+	mods  QueryMods
+	guard BypassGuard
+	// Kinds removed by UseContextWithoutMods, which stay removed when a definition
+	// uses them again, and those of them the guard allowed.
+	bypass  []string
+	allowed []string
 }
 
 var ErrNoContext = errors.New("no db context")
@@ -27,13 +33,49 @@ func createDbCtx(ctx context.Context, db bun.IDB, bindings QueryMods) context.Co
 	})
 }
 
+// with derives a context for db and mods that keeps the guard and the bypassed kinds.
+func (dbCtx *dbCtx) with(ctx context.Context, db bun.IDB, mods QueryMods) context.Context {
+	next := *dbCtx
+	next.db = db
+	next.mods = mods
+	return context.WithValue(ctx, dbCtxKey{}, &next)
+}
+
 func NewContext(ctx context.Context, db bun.IDB, mods ...QueryMod) context.Context {
 	return createDbCtx(ctx, db, mods)
 }
 
 func UseContextMods(ctx context.Context, mods ...QueryMod) (context.Context, error) {
 	if dbCtx, ok := getDbCtx(ctx); ok {
-		return createDbCtx(ctx, dbCtx.db, mods), nil
+		return dbCtx.with(ctx, dbCtx.db, mods), nil
+	}
+	return ctx, ErrNoContext
+}
+
+func UseContextWithoutMods(ctx context.Context, kinds ...string) (context.Context, error) {
+	if dbCtx, ok := getDbCtx(ctx); ok {
+		if mods, err := dbCtx.without(ctx, dbCtx.mods, kinds...); err != nil {
+			return ctx, err
+		} else {
+			next := *dbCtx
+			next.mods = mods
+			next.bypass = append(slices.Clone(dbCtx.bypass), kinds...)
+			for _, kind := range kinds {
+				if dbCtx.mods.Has(kind) {
+					next.allowed = append(slices.Clone(next.allowed), kind)
+				}
+			}
+			return context.WithValue(ctx, dbCtxKey{}, &next), nil
+		}
+	}
+	return ctx, ErrNoContext
+}
+
+func UseBypassGuard(ctx context.Context, guard BypassGuard) (context.Context, error) {
+	if dbCtx, ok := getDbCtx(ctx); ok {
+		next := *dbCtx
+		next.guard = guard
+		return context.WithValue(ctx, dbCtxKey{}, &next), nil
 	}
 	return ctx, ErrNoContext
 }
@@ -44,3 +86,28 @@ func FromContext(ctx context.Context) (bun.IDB, QueryMods, error) {
 	}
 	return nil, nil, ErrNoContext
 }
+
+// resolveMods adds the mods of a definition and then removes the kinds bypassed by the
+// options and the context, so a definition can't bring back a bypassed kind.
+func (dbCtx *dbCtx) resolveMods(ctx context.Context, opt *QueryOpts) (QueryMods, error) {
+	return dbCtx.without(ctx, dbCtx.mods.Use(opt.Mods...), append(slices.Clone(opt.Without), dbCtx.bypass...)...)
+}
+
+func (dbCtx *dbCtx) without(ctx context.Context, mods QueryMods, kinds ...string) (QueryMods, error) {
+	for _, kind := range kinds {
+		if !mods.Has(kind) {
+			continue
+		}
+		if slices.Contains(dbCtx.allowed, kind) {
+			continue
+		}
+		if dbCtx.guard == nil {
+			return nil, &BypassError{Kind: kind, Err: ErrNoBypassGuard}
+		}
+		if err := dbCtx.guard.AllowBypass(ctx, kind); err != nil {
+			return nil, &BypassError{Kind: kind, Err: err}
+		}
+		dbCtx.guard.Bypassed(ctx, kind)
+	}
+	return mods.Without(kinds...), nil
+}
@@ -1,8 +1,15 @@
 package bunquery
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -12,31 +19,101 @@ const (
 	flagInclude
 )
 
+var ErrContinuationSignature = errors.New("continuation token signature is invalid")
+var ErrContinuationKey = errors.New("continuation token is not encrypted with a known key")
+
+type ContinuationOptions struct {
+	SigningKeys    [][]byte
+	EncryptionKeys [][]byte
+}
+
+type ContinuationOption = func(*ContinuationOptions)
+
+// WithSigningKeys signs tokens with HMAC-SHA256 using the first key and only accepts
+// tokens signed with one of the keys, so keys can be rotated by prepending a new one.
+// Formatting and parsing tokens fail when any of the keys is empty.
+func WithSigningKeys(keys ...[]byte) ContinuationOption {
+	return func(opts *ContinuationOptions) {
+		opts.SigningKeys = append(opts.SigningKeys, keys...)
+	}
+}
+
+// WithEncryptionKeys encrypts tokens with AES-GCM using the first key and decrypts them
+// with whichever key fits, so keys can be rotated by prepending a new one. Keys are 16,
+// 24 or 32 bytes long. Encrypted tokens are URL-safe base64.
+func WithEncryptionKeys(keys ...[]byte) ContinuationOption {
+	return func(opts *ContinuationOptions) {
+		opts.EncryptionKeys = append(opts.EncryptionKeys, keys...)
+	}
+}
+
+func newContinuationOptions(opts ...ContinuationOption) *ContinuationOptions {
+	res := &ContinuationOptions{}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
 type rawContinuation struct {
+	K uint32
+	D []byte // direction bits, one per value
+	F uint8
+	V []any
+}
+
+// legacyContinuation is the format of tokens that packed the directions in one byte.
+type legacyContinuation struct {
 	K uint32
 	D uint8
 	F uint8
 	V []any
 }
 
-func decodeRawContinuation(token string) (*rawContinuation, error) {
-	b, err := base64.StdEncoding.DecodeString(token)
-	if err != nil {
+func decodeRawContinuation(token string, opts *ContinuationOptions) (*rawContinuation, error) {
+	if len(opts.SigningKeys) > 0 {
+		if err := checkSigningKeys(opts.SigningKeys); err != nil {
+			return nil, err
+		}
+		payload, sig, ok := strings.Cut(token, ".")
+		if !ok || !verifyContinuation(opts.SigningKeys, payload, sig) {
+			return nil, ErrContinuationSignature
+		}
+		token = payload
+	}
+
+	var b []byte
+	var err error
+	if len(opts.EncryptionKeys) > 0 {
+		if b, err = base64.RawURLEncoding.DecodeString(token); err != nil {
+			return nil, err
+		} else if b, err = openContinuation(opts.EncryptionKeys, b); err != nil {
+			return nil, err
+		}
+	} else if b, err = base64.StdEncoding.DecodeString(token); err != nil {
 		return nil, err
 	}
 
 	c := &rawContinuation{}
 	if err := msgpack.Unmarshal(b, c); err != nil {
-		return nil, err
+		legacy := &legacyContinuation{}
+		if msgpack.Unmarshal(b, legacy) != nil {
+			return nil, err
+		}
+		c = &rawContinuation{K: legacy.K, D: []byte{legacy.D}, F: legacy.F, V: legacy.V}
 	}
 
 	return c, nil
 }
 
 func (raw *rawContinuation) Continuation() *Continuation {
-	var dirs []uint8
-	for i := range 8 {
-		dirs = append(dirs, (raw.D>>i)&0x01)
+	num := len(raw.D) * 8
+	if len(raw.V) > 0 {
+		num = min(num, len(raw.V))
+	}
+	dirs := make([]uint8, 0, num)
+	for i := range num {
+		dirs = append(dirs, (raw.D[i/8]>>(i%8))&0x01)
 	}
 	return &Continuation{
 		SID:        raw.K,
@@ -47,12 +124,89 @@ func (raw *rawContinuation) Continuation() *Continuation {
 	}
 }
 
-func (raw *rawContinuation) String() (string, error) {
-	if b, err := msgpack.Marshal(raw); err != nil {
+func (raw *rawContinuation) String(opts *ContinuationOptions) (string, error) {
+	b, err := msgpack.Marshal(raw)
+	if err != nil {
+		return "", err
+	} else if err := checkSigningKeys(opts.SigningKeys); err != nil {
 		return "", err
+	}
+
+	var token string
+	if len(opts.EncryptionKeys) > 0 {
+		if b, err = sealContinuation(opts.EncryptionKeys[0], b); err != nil {
+			return "", err
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
 	} else {
-		return base64.StdEncoding.EncodeToString(b), nil
+		token = base64.StdEncoding.EncodeToString(b)
+	}
+	if len(opts.SigningKeys) > 0 {
+		token += "." + signContinuation(opts.SigningKeys[0], token)
+	}
+	return token, nil
+}
+
+func checkSigningKeys(keys [][]byte) error {
+	for i, key := range keys {
+		if len(key) == 0 {
+			return fmt.Errorf("continuation signing key %d is empty", i)
+		}
+	}
+	return nil
+}
+
+func signContinuation(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newContinuationAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealContinuation encrypts the payload and prepends the nonce.
+func sealContinuation(key []byte, payload []byte) ([]byte, error) {
+	aead, err := newContinuationAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, payload, nil), nil
+}
+
+func openContinuation(keys [][]byte, sealed []byte) ([]byte, error) {
+	for _, key := range keys {
+		aead, err := newContinuationAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, ErrContinuationKey
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if payload, err := aead.Open(nil, nonce, ciphertext, nil); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, ErrContinuationKey
+}
+
+func verifyContinuation(keys [][]byte, payload, sig string) bool {
+	for _, key := range keys {
+		if hmac.Equal([]byte(sig), []byte(signContinuation(key, payload))) {
+			return true
+		}
 	}
+	return false
 }
 
 type Continuation struct {
@@ -74,9 +228,9 @@ func NewContinuation(sid uint32, directions []uint8, values []any, reverse, incl
 }
 
 func (c *Continuation) raw() *rawContinuation {
-	d := uint8(0)
+	d := make([]byte, (len(c.Directions)+7)/8)
 	for i, dir := range c.Directions {
-		d |= (dir & 0x1) << i
+		d[i/8] |= (dir & 0x1) << (i % 8)
 	}
 	p := &rawContinuation{
 		K: c.SID,
@@ -92,17 +246,20 @@ func (c *Continuation) raw() *rawContinuation {
 	return p
 }
 
-func (c *Continuation) String() (string, error) {
-	return c.raw().String()
+func (c *Continuation) String(opts ...ContinuationOption) (string, error) {
+	return c.raw().String(newContinuationOptions(opts...))
 }
 
-func FormatContinuation(sid uint32, directions []uint8, values []any, reverse, include bool) (string, error) {
+func FormatContinuation(sid uint32, directions []uint8, values []any, reverse, include bool, opts ...ContinuationOption) (string, error) {
 	res := NewContinuation(sid, directions, values, reverse, include)
-	return res.String()
+	return res.String(opts...)
 }
 
-func ParseContinuation(token string) (*Continuation, error) {
-	if c, err := decodeRawContinuation(token); err != nil {
+// ParseContinuation fails with ErrContinuationSignature when signing keys are given
+// and the token is not signed with one of them, and with ErrContinuationKey when
+// encryption keys are given and none of them decrypts the token.
+func ParseContinuation(token string, opts ...ContinuationOption) (*Continuation, error) {
+	if c, err := decodeRawContinuation(token, newContinuationOptions(opts...)); err != nil {
 		return nil, fmt.Errorf("failed to parse continue token: %w", err)
 	} else {
 		return c.Continuation(), nil
@@ -0,0 +1,88 @@
+package bunquery_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/mmorton/bunquery"
+)
+
+func TestContinuationDirections(t *testing.T) {
+	dirs := []uint8{0, 1, 0, 0, 1, 1, 0, 1, 1, 0, 1}
+	values := []any{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8), int64(9), int64(10), int64(11)}
+	token, err := bunquery.FormatContinuation(7, dirs, values, true, false)
+	assert.NoError(t, err)
+	cont, err := bunquery.ParseContinuation(token)
+	assert.NoError(t, err)
+	assert.Equal(t, bunquery.NewContinuation(7, dirs, values, true, false), cont)
+
+	// Tokens that packed the directions in one byte still parse.
+	b, err := msgpack.Marshal(struct {
+		K uint32
+		D uint8
+		F uint8
+		V []any
+	}{K: 7, D: 0x02, V: []any{"a", "b"}})
+	assert.NoError(t, err)
+	cont, err = bunquery.ParseContinuation(base64.StdEncoding.EncodeToString(b))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0, 1}, cont.Directions)
+	assert.Equal(t, []any{"a", "b"}, cont.Values)
+}
+
+func TestContinuationSigning(t *testing.T) {
+	oldKey, newKey := []byte("old"), []byte("new")
+	token, err := bunquery.FormatContinuation(7, []uint8{1}, []any{"a"}, false, false, bunquery.WithSigningKeys(oldKey))
+	assert.NoError(t, err)
+
+	cont, err := bunquery.ParseContinuation(token, bunquery.WithSigningKeys(newKey, oldKey))
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"a"}, cont.Values)
+
+	_, err = bunquery.ParseContinuation(token, bunquery.WithSigningKeys(newKey))
+	assert.True(t, errors.Is(err, bunquery.ErrContinuationSignature))
+
+	payload, sig, _ := strings.Cut(token, ".")
+	forged, err := bunquery.FormatContinuation(8, []uint8{1}, []any{"a"}, false, false)
+	assert.NoError(t, err)
+	assert.NotEqual(t, payload, forged)
+	for _, token := range []string{forged, forged + "." + sig, payload + "." + sig[1:]} {
+		_, err = bunquery.ParseContinuation(token, bunquery.WithSigningKeys(oldKey))
+		assert.True(t, errors.Is(err, bunquery.ErrContinuationSignature))
+	}
+
+	_, err = bunquery.FormatContinuation(7, []uint8{1}, []any{"a"}, false, false, bunquery.WithSigningKeys(nil))
+	assert.ErrorContains(t, err, "signing key 0 is empty")
+	_, err = bunquery.ParseContinuation(token, bunquery.WithSigningKeys(oldKey, []byte{}))
+	assert.ErrorContains(t, err, "signing key 1 is empty")
+}
+
+func TestContinuationEncryption(t *testing.T) {
+	oldKey, newKey := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+	token, err := bunquery.FormatContinuation(7, []uint8{1}, []any{"a@example.com"}, false, false, bunquery.WithEncryptionKeys(oldKey))
+	assert.NoError(t, err)
+	assert.Equal(t, url.QueryEscape(token), token)
+	plain, err := base64.RawURLEncoding.DecodeString(token)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(plain), "a@example.com")
+
+	cont, err := bunquery.ParseContinuation(token, bunquery.WithEncryptionKeys(newKey, oldKey))
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"a@example.com"}, cont.Values)
+
+	_, err = bunquery.ParseContinuation(token, bunquery.WithEncryptionKeys(newKey))
+	assert.True(t, errors.Is(err, bunquery.ErrContinuationKey))
+
+	signed, err := bunquery.FormatContinuation(7, []uint8{1}, []any{"a"}, false, false, bunquery.WithEncryptionKeys(newKey), bunquery.WithSigningKeys(oldKey))
+	assert.NoError(t, err)
+	assert.Equal(t, url.QueryEscape(signed), signed)
+	cont, err = bunquery.ParseContinuation(signed, bunquery.WithEncryptionKeys(newKey), bunquery.WithSigningKeys(oldKey))
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"a"}, cont.Values)
+}
@@ -0,0 +1,31 @@
+package bunquery_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	_ "modernc.org/sqlite"
+)
+
+// newSQLiteDB opens an in-memory database with tables for the models.
+func newSQLiteDB(t *testing.T, models ...any) *bun.DB {
+	t.Helper()
+	sqldb, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { db.Close() })
+
+	for _, model := range models {
+		if _, err := db.NewCreateTable().Model(model).Exec(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
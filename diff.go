@@ -0,0 +1,107 @@
+package bunquery
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var ErrPatchEmpty = errors.New("patch has no changes")
+
+// Diff is a patch of the columns that differ between two snapshots of a model.
+type Diff[Target any] struct {
+	after   *Target
+	cols    []string
+	changed []string
+	opts    *PatchOptions
+}
+
+// DiffPatch compares the columns of two snapshots of a model by their values. Times are
+// equal when they are the same instant, and driver.Valuer values, such as sql.Null*,
+// when their driver values are. Compiling an empty diff fails with ErrPatchEmpty, check
+// Empty to skip the update.
+func DiffPatch[Target any](before, after *Target, opts ...PatchOption) *Diff[Target] {
+	diff := &Diff[Target]{after: after, opts: newPatchOptions(opts...)}
+
+	table := modelTables.Get(reflect.TypeOf(after))
+	bv, av := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+	for _, field := range table.DataFields {
+		if field.StructField.Tag.Get("bunpatch") == "-" {
+			continue
+		}
+		if !diffValuesEqual(field.Value(bv), field.Value(av)) {
+			diff.cols = append(diff.cols, field.Name)
+			diff.changed = append(diff.changed, field.GoName)
+		}
+	}
+	return diff
+}
+
+func diffValuesEqual(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Pointer {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return diffValuesEqual(a.Elem(), b.Elem())
+	}
+	switch av := a.Interface().(type) {
+	case time.Time:
+		bv, ok := b.Interface().(time.Time)
+		return ok && av.Equal(bv)
+	case driver.Valuer:
+		bv, ok := b.Interface().(driver.Valuer)
+		if !ok {
+			return false
+		}
+		x, errA := av.Value()
+		y, errB := bv.Value()
+		if errA != nil || errB != nil {
+			return false
+		}
+		if x, ok := x.(time.Time); ok {
+			y, ok := y.(time.Time)
+			return ok && x.Equal(y)
+		}
+		return reflect.DeepEqual(x, y)
+	default:
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+}
+
+func (diff *Diff[Target]) Target() *Target {
+	return diff.after
+}
+
+// Changed returns the Go names of the fields that differ.
+func (diff *Diff[Target]) Changed() []string {
+	return diff.changed
+}
+
+func (diff *Diff[Target]) Empty() bool {
+	return len(diff.cols) == 0
+}
+
+func (diff *Diff[Target]) Compile() func(*bun.UpdateQuery) *bun.UpdateQuery {
+	return func(query *bun.UpdateQuery) *bun.UpdateQuery {
+		if diff.Empty() {
+			query.Err(ErrPatchEmpty)
+			return query
+		}
+
+		ps := newPatchSet(query, diff.Target(), diff.opts)
+		strct := reflect.ValueOf(diff.after).Elem()
+		for _, col := range diff.cols {
+			field, ok := ps.table.FieldMap[col]
+			if !ok {
+				query.Err(fmt.Errorf("%s does not have column %s", ps.table.TypeName, col))
+				return query
+			}
+			ps.set(field, field.Value(strct))
+		}
+		return ps.finish()
+	}
+}
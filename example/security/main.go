@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"github.com/uptrace/bun/driver/sqliteshim"
 	"github.com/uptrace/bun/extra/bundebug"
+	"github.com/uptrace/bun/schema"
 
 	"github.com/mmorton/bunquery"
 )
@@ -37,9 +39,12 @@ func GetCurrentUserID(ctx context.Context) (int64, error) {
 type SecurityQueryMod struct {
 }
 
+var publicTables = []string{"users"}
+
 var _ bunquery.QueryMod = (*SecurityQueryMod)(nil)
 
-func (sb *SecurityQueryMod) Kind() string { return "security" }
+func (sb *SecurityQueryMod) Kind() string      { return "security" }
+func (sb *SecurityQueryMod) Restrictive() bool { return true }
 func (sb *SecurityQueryMod) Bind(ctx context.Context, db bun.IDB, qry bunquery.QueryBuilderEx, args ...any) {
 	currentUserID, err := GetCurrentUserID(ctx)
 	if err != nil {
@@ -47,7 +52,19 @@ func (sb *SecurityQueryMod) Bind(ctx context.Context, db bun.IDB, qry bunquery.Q
 		return
 	}
 
-	qry.Where("author_id = ?", currentUserID)
+	// Rows are denied unless the table is public or has an author to filter on.
+	qry.WhereTable(func(table *schema.Table) (string, []any) {
+		if table == nil {
+			return "1 = 0", nil
+		}
+		if field, ok := table.FieldMap["author_id"]; ok {
+			return "?TableAlias.? = ?", []any{bun.Safe(field.SQLName), currentUserID}
+		}
+		if slices.Contains(publicTables, table.Name) {
+			return "", nil
+		}
+		return "1 = 0", nil
+	})
 }
 
 type GetMyStoriesArgs struct {
@@ -56,7 +73,7 @@ type GetMyStoriesArgs struct {
 var GetMyStories = bunquery.CreateQuery(bunquery.Query[GetMyStoriesArgs, []Story]{
 	Handler: func(ctx context.Context, db bunquery.QueryDB, args GetMyStoriesArgs) ([]Story, error) {
 		stories := make([]Story, 0)
-		if err := db.NewSelect().Model(&stories).Relation("Author").OrderExpr("story.id ASC").Scan(ctx); err != nil {
+		if err := db.NewSelect().Model(&stories).Apply(bunquery.Relation(db, "Author")).OrderExpr("story.id ASC").Scan(ctx); err != nil {
 			return nil, err
 		}
 		return stories, nil
@@ -0,0 +1,6 @@
+package bunquery
+
+// Internals exported for the tests of package bunquery_test.
+
+var GetSQLComment = getSQLComment
+var AppendSQLComment = appendSQLComment
@@ -0,0 +1,125 @@
+package bunquery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+type ForbiddenFieldsError struct {
+	Table  string
+	Fields []string
+}
+
+func (e *ForbiddenFieldsError) Error() string {
+	return fmt.Sprintf("not allowed to set %s of %s", strings.Join(e.Fields, ", "), e.Table)
+}
+
+// FieldPolicy reports whether the principal of the context may set a field.
+type FieldPolicy func(ctx context.Context, table *schema.Table, field *schema.Field) bool
+
+type FieldAuthOptions struct {
+	Roles  func(ctx context.Context) []string
+	Policy FieldPolicy
+}
+
+type FieldAuthOption = func(*FieldAuthOptions)
+
+// WithFieldRoles reads the roles of the principal from the context. Model fields tagged
+// `bunauth:"admin,owner"` may only be set by principals with one of the listed roles.
+func WithFieldRoles(roles func(ctx context.Context) []string) FieldAuthOption {
+	return func(opts *FieldAuthOptions) {
+		opts.Roles = roles
+	}
+}
+
+// WithFieldPolicy adds a policy that is checked for every field, after the roles.
+func WithFieldPolicy(policy FieldPolicy) FieldAuthOption {
+	return func(opts *FieldAuthOptions) {
+		opts.Policy = policy
+	}
+}
+
+// FieldAuthMod fails inserts and updates that write fields the principal is not allowed
+// to set with an ForbiddenFieldsError that lists them all. Updates are checked for the
+// columns they write: the non-PK fields of the model, those of its column list, or those
+// of its SET clauses. Inserts are checked for every column they write, zero or not, so
+// principals leave out forbidden fields with a column list, and for the columns of ON
+// CONFLICT DO UPDATE. Statements whose columns can't be told, such as SET clauses that
+// aren't plain assignments, are denied.
+type FieldAuthMod struct {
+	opts *FieldAuthOptions
+}
+
+var _ QueryMod = (*FieldAuthMod)(nil)
+var _ MutationHook = (*FieldAuthMod)(nil)
+
+func NewFieldAuthMod(opts ...FieldAuthOption) *FieldAuthMod {
+	res := &FieldAuthOptions{}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return &FieldAuthMod{opts: res}
+}
+
+func (m *FieldAuthMod) Kind() string { return "fieldauth" }
+
+func (m *FieldAuthMod) Bind(ctx context.Context, db bun.IDB, qry QueryBuilderEx, args ...any) {}
+
+func (m *FieldAuthMod) allowed(ctx context.Context, roles []string, table *schema.Table, field *schema.Field) bool {
+	if tag, ok := field.StructField.Tag.Lookup("bunauth"); ok {
+		if !slices.ContainsFunc(strings.Split(tag, ","), func(role string) bool {
+			return slices.Contains(roles, strings.TrimSpace(role))
+		}) {
+			return false
+		}
+	}
+	return m.opts.Policy == nil || m.opts.Policy(ctx, table, field)
+}
+
+func (m *FieldAuthMod) BeforeMutation(ctx context.Context, db bun.IDB, event *MutationEvent) (context.Context, error) {
+	kind := event.Kind()
+	if kind != QueryUpdate && kind != QueryInsert {
+		return ctx, nil
+	}
+	if event.Table == nil {
+		return ctx, fmt.Errorf("fieldauth: can't determine the columns written by %s without a model", event.Operation())
+	}
+	writes := getQueryWrites(event.Query, event.Table)
+	if writes.exprs {
+		return ctx, fmt.Errorf("fieldauth: can't determine the columns written by %s of %s", event.Operation(), event.Table.TypeName)
+	}
+
+	fields := slices.Clone(writes.fields)
+	for _, col := range writes.set {
+		if field, ok := event.Table.FieldMap[col]; ok && !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	var roles []string
+	if m.opts.Roles != nil {
+		roles = m.opts.Roles(ctx)
+	}
+
+	var forbidden []string
+	for _, field := range fields {
+		if !m.allowed(ctx, roles, event.Table, field) {
+			forbidden = append(forbidden, field.GoName)
+		}
+	}
+	if len(forbidden) > 0 {
+		slices.Sort(forbidden)
+		return ctx, &ForbiddenFieldsError{Table: event.Table.TypeName, Fields: forbidden}
+	}
+	return ctx, nil
+}
+
+func (m *FieldAuthMod) AfterMutation(ctx context.Context, db bun.IDB, event *MutationEvent, res sql.Result) error {
+	return nil
+}
@@ -0,0 +1,117 @@
+package bunquery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmorton/bunquery"
+)
+
+type authAccount struct {
+	ID      int64 `bun:",pk"`
+	Name    string
+	IsAdmin bool `bunauth:"admin"`
+}
+
+type authAccountPatch struct {
+	bunquery.Patch[authAccount, authAccountPatch]
+	Name    *string
+	IsAdmin *bool
+}
+
+type rolesKey struct{}
+
+func TestFieldAuthMod(t *testing.T) {
+	db := newSQLiteDB(t, (*authAccount)(nil))
+	ctx := bunquery.NewContext(context.Background(), db, bunquery.NewFieldAuthMod(
+		bunquery.WithFieldRoles(func(ctx context.Context) []string {
+			roles, _ := ctx.Value(rolesKey{}).([]string)
+			return roles
+		}),
+	))
+	admin := context.WithValue(ctx, rolesKey{}, []string{"admin"})
+	_, err := db.NewInsert().Model(&authAccount{ID: 1, Name: "a"}).Exec(ctx)
+	assert.NoError(t, err)
+
+	mutate := func(ctx context.Context, fn func(ctx context.Context, db bunquery.MutationDB) error) error {
+		return bunquery.UseMutation(ctx, fn)
+	}
+	assertForbidden := func(t *testing.T, err error) {
+		t.Helper()
+		var forbidden *bunquery.ForbiddenFieldsError
+		if assert.ErrorAs(t, err, &forbidden) {
+			assert.Equal(t, []string{"IsAdmin"}, forbidden.Fields)
+		}
+	}
+
+	for name, fn := range map[string]func(ctx context.Context, db bunquery.MutationDB) error{
+		"full model": func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewUpdate().Model(&authAccount{ID: 1, Name: "b"}).WherePK().Exec(ctx)
+			return err
+		},
+		"column list": func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewUpdate().Model(&authAccount{ID: 1, IsAdmin: true}).Column("is_admin").WherePK().Exec(ctx)
+			return err
+		},
+		"set clause": func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewUpdate().Model((*authAccount)(nil)).Set("is_admin = ?", true).Where("id = 1").Exec(ctx)
+			return err
+		},
+		"patch": func(ctx context.Context, db bunquery.MutationDB) error {
+			patch := &authAccountPatch{IsAdmin: new(bool)}
+			patch.Patch = bunquery.CreatePatch(&authAccount{ID: 1}, patch)
+			_, err := db.NewUpdate().Apply(patch.Compile()).Exec(ctx)
+			return err
+		},
+		"insert": func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewInsert().Model(&authAccount{ID: 2, IsAdmin: true}).Exec(ctx)
+			return err
+		},
+		"insert value": func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewInsert().Model(&authAccount{ID: 2}).Value("is_admin", "TRUE").Exec(ctx)
+			return err
+		},
+		"upsert": func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewInsert().Model(&authAccount{ID: 1, Name: "b"}).On("CONFLICT (id) DO UPDATE").Set("is_admin = TRUE").Exec(ctx)
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertForbidden(t, mutate(ctx, fn))
+			assert.NoError(t, mutate(admin, fn))
+			_, err := db.NewDelete().Model((*authAccount)(nil)).Where("id = 2").Exec(ctx)
+			assert.NoError(t, err)
+		})
+	}
+
+	// Inserts write every column, even zero ones, unless they are left out.
+	assertForbidden(t, mutate(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewInsert().Model(&authAccount{ID: 3, Name: "c"}).Exec(ctx)
+		return err
+	}))
+
+	// Columns the principal may set pass.
+	assert.NoError(t, mutate(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		if _, err := db.NewInsert().Model(&authAccount{ID: 3, Name: "c"}).ExcludeColumn("is_admin").Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewUpdate().Model(&authAccount{ID: 1, Name: "b"}).Column("name").WherePK().Exec(ctx)
+		return err
+	}))
+
+	// Statements whose columns can't be told are denied.
+	for _, fn := range []func(ctx context.Context, db bunquery.MutationDB) error{
+		func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewUpdate().Table("auth_accounts").Set("is_admin = TRUE").Where("id = 1").Exec(ctx)
+			return err
+		},
+		func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewUpdate().Model((*authAccount)(nil)).Set("(is_admin, name) = (TRUE, 'x')").Where("id = 1").Exec(ctx)
+			return err
+		},
+	} {
+		assert.ErrorContains(t, mutate(admin, fn), "fieldauth: can't determine the columns written")
+	}
+}
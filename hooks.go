@@ -0,0 +1,211 @@
+package bunquery
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// MutationHook is implemented by QueryMods that need to observe inserts, updates
+// and deletes executed through a MutationDB. Hooks run inside the mutation's Tx.
+type MutationHook interface {
+	BeforeMutation(ctx context.Context, db bun.IDB, event *MutationEvent) (context.Context, error)
+	AfterMutation(ctx context.Context, db bun.IDB, event *MutationEvent, res sql.Result) error
+}
+
+type MutationEvent struct {
+	Query bun.Query
+	Table *schema.Table
+	// Changes holds the columns set by Patch.Compile, nil for other queries. Columns set
+	// by expressions, such as JSON patch edits, hold the value read back after the update.
+	Changes map[string]any
+	rebuild bool
+	// beforeAfter runs once the statement was executed, before the after hooks.
+	beforeAfter []func(ctx context.Context, db bun.IDB, res sql.Result) error
+	// afterExec runs once the statement was executed and the after hooks succeeded.
+	afterExec []func(ctx context.Context, db bun.IDB, res sql.Result) error
+}
+
+func (event *MutationEvent) Operation() string {
+	return event.Query.Operation()
+}
+
+func (event *MutationEvent) Kind() QueryKind {
+	return getQueryKind(event.Query)
+}
+
+// Models returns the struct values of the query model, one per row.
+func (event *MutationEvent) Models() []reflect.Value {
+	if event.Table == nil {
+		return nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(event.Query.GetModel().Value()))
+	switch v.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{v}
+	case reflect.Slice:
+		res := make([]reflect.Value, 0, v.Len())
+		for i := range v.Len() {
+			res = append(res, reflect.Indirect(v.Index(i)))
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+// Rebuild must be called by hooks that modify the query in BeforeMutation so the
+// statement is generated again before it is executed. Query hooks registered on
+// the bun.DB still report the statement as it was before the rebuild.
+func (event *MutationEvent) Rebuild() {
+	event.rebuild = true
+}
+
+var mutationEvents sync.Map
+
+func lookupMutationEvent(query bun.Query) (*MutationEvent, bool) {
+	if v, ok := mutationEvents.Load(query); ok {
+		return v.(*MutationEvent), true
+	}
+	return nil, false
+}
+
+type mutationState struct {
+	mu      sync.Mutex
+	queries []bun.Query
+	pending []func() error
+}
+
+func (st *mutationState) track(query bun.Query, event *MutationEvent) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.queries = append(st.queries, query)
+	mutationEvents.Store(query, event)
+}
+
+func (st *mutationState) deferAfter(fn func() error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.pending = append(st.pending, fn)
+}
+
+// flush runs the hooks that could not run while rows were still being read.
+func (st *mutationState) flush() error {
+	st.mu.Lock()
+	pending := st.pending
+	st.pending = nil
+	st.mu.Unlock()
+	for _, fn := range pending {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *mutationState) release() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, query := range st.queries {
+		mutationEvents.Delete(query)
+	}
+	st.queries = nil
+}
+
+type mutationConn struct {
+	bun.IConn
+	db    bun.IDB
+	state *mutationState
+	hooks []MutationHook
+	event *MutationEvent
+}
+
+func getMutationHooks(mods QueryMods) []MutationHook {
+	var hooks []MutationHook
+	for _, mod := range mods {
+		if hook, ok := mod.(MutationHook); ok {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+func (conn *mutationConn) before(ctx context.Context, query string) ([]context.Context, string, error) {
+	// The rows of earlier statements have been read, so their hooks run first.
+	if err := conn.state.flush(); err != nil {
+		return nil, "", err
+	}
+	conn.event.Table = getQueryTable(conn.event.Query)
+
+	ctxs := make([]context.Context, len(conn.hooks))
+	for i, hook := range conn.hooks {
+		hctx, err := hook.BeforeMutation(ctx, conn.db, conn.event)
+		if err != nil {
+			return nil, "", err
+		}
+		ctxs[i] = hctx
+	}
+
+	if conn.event.rebuild {
+		conn.event.rebuild = false
+		gen := conn.event.Query.(interface{ DB() *bun.DB }).DB().QueryGen()
+		if b, err := conn.event.Query.AppendQuery(gen, nil); err != nil {
+			return nil, "", err
+		} else {
+			query = string(b)
+		}
+	}
+
+	return ctxs, query, nil
+}
+
+func (conn *mutationConn) after(ctx context.Context, ctxs []context.Context, res sql.Result) error {
+	for _, fn := range conn.event.beforeAfter {
+		if err := fn(ctx, conn.db, res); err != nil {
+			return err
+		}
+	}
+	for i, hook := range conn.hooks {
+		if err := hook.AfterMutation(ctxs[i], conn.db, conn.event, res); err != nil {
+			return err
+		}
+	}
+	for _, fn := range conn.event.afterExec {
+		if err := fn(ctx, conn.db, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (conn *mutationConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctxs, query, err := conn.before(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	res, err := conn.IConn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return res, err
+	}
+	return res, conn.after(ctx, ctxs, res)
+}
+
+func (conn *mutationConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctxs, query, err := conn.before(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.IConn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return rows, err
+	}
+	// The connection is busy until the rows are read, so after hooks run when the mutation ends.
+	conn.state.deferAfter(func() error {
+		return conn.after(ctx, ctxs, nil)
+	})
+	return rows, nil
+}
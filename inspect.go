@@ -0,0 +1,155 @@
+package bunquery
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// getQueryField reads a clause of a query. bun has no accessors for them, so they are
+// read from its unexported fields.
+func getQueryField[T any](query bun.Query, name string) (T, bool) {
+	var zero T
+	v := reflect.ValueOf(query)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return zero, false
+	}
+	f := v.Elem().FieldByName(name)
+	if !f.IsValid() || f.Type() != reflect.TypeFor[T]() {
+		return zero, false
+	}
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Interface().(T), true
+}
+
+// copyQueryWhere gives dst the WHERE clauses of query, so dst selects the rows that
+// query changes. It reports false when query has clauses that can't be copied.
+func copyQueryWhere(dst *bun.SelectQuery, query bun.Query) bool {
+	where, ok := getQueryField[[]schema.QueryWithSep](query, "where")
+	if !ok {
+		return false
+	}
+	if fields, _ := getQueryField[[]*schema.Field](query, "whereFields"); len(fields) > 0 {
+		return false
+	}
+	if tables, _ := getQueryField[[]schema.QueryWithArgs](query, "tables"); len(tables) > 0 {
+		return false
+	}
+	f := reflect.ValueOf(dst).Elem().FieldByName("where")
+	reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Set(reflect.ValueOf(slices.Clone(where)))
+	return true
+}
+
+// queryWrites lists the columns an insert or update writes.
+type queryWrites struct {
+	fields []*schema.Field // model fields written from the struct values
+	set    []string        // columns assigned by SET clauses
+	exprs  bool            // SET clauses that don't assign plain columns
+}
+
+func (w *queryWrites) has(col string) bool {
+	return w.hasField(col) || slices.Contains(w.set, col)
+}
+
+func (w *queryWrites) hasField(col string) bool {
+	return slices.ContainsFunc(w.fields, func(field *schema.Field) bool { return field.Name == col })
+}
+
+// getQueryWrites follows bun: an update writes the model fields, or those in its column
+// list, unless it has SET clauses without a column list, and a nil model writes none.
+// The SET clauses of an insert are those of ON CONFLICT DO UPDATE.
+func getQueryWrites(query bun.Query, table *schema.Table) *queryWrites {
+	res := &queryWrites{}
+	set, _ := getQueryField[[]schema.QueryWithArgs](query, "set")
+	columns, _ := getQueryField[[]schema.QueryWithArgs](query, "columns")
+
+	var fields []*schema.Field
+	switch query.(type) {
+	case *bun.UpdateQuery:
+		if table != nil && hasStructModel(query) && (len(set) == 0 || columns != nil) {
+			fields = table.DataFields
+		}
+	case *bun.InsertQuery:
+		if table != nil {
+			fields = table.Fields
+		}
+	}
+	for _, field := range fields {
+		if len(columns) == 0 || slices.ContainsFunc(columns, func(col schema.QueryWithArgs) bool {
+			return col.Args == nil && col.Query == field.Name
+		}) {
+			res.fields = append(res.fields, field)
+		}
+	}
+
+	if len(set) == 0 {
+		return res
+	}
+	gen := query.(interface{ DB() *bun.DB }).DB().QueryGen()
+	for _, clause := range set {
+		for _, assign := range splitSQLList(string(gen.AppendQuery(nil, clause.Query, clause.Args...))) {
+			if col, ok := getAssignedColumn(assign); ok {
+				res.set = append(res.set, col)
+			} else {
+				res.exprs = true
+			}
+		}
+	}
+	return res
+}
+
+func hasStructModel(query bun.Query) bool {
+	if query.GetModel() == nil {
+		return false
+	}
+	v := reflect.ValueOf(query.GetModel().Value())
+	return v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct
+}
+
+// splitSQLList splits SQL at the commas that are not quoted or in parentheses.
+func splitSQLList(sql string) []string {
+	var res []string
+	var quote rune
+	depth, start := 0, 0
+	for i, r := range sql {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			res = append(res, sql[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, sql[start:])
+}
+
+// getAssignedColumn returns the column of a "column = value" assignment, without its
+// table and quotes.
+func getAssignedColumn(assign string) (string, bool) {
+	lhs, _, ok := strings.Cut(assign, "=")
+	if !ok {
+		return "", false
+	}
+	lhs = strings.TrimSpace(lhs)
+	if idx := strings.LastIndex(lhs, "."); idx != -1 {
+		lhs = lhs[idx+1:]
+	}
+	lhs = strings.Trim(lhs, "\"`[]")
+	if lhs == "" || strings.ContainsFunc(lhs, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		return "", false
+	}
+	return lhs, true
+}
@@ -0,0 +1,251 @@
+package bunquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+var jsonPathKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// appendColumn appends a column operand, where a string names the column.
+func appendColumn(fmter schema.QueryGen, b []byte, col any) ([]byte, error) {
+	if name, ok := col.(string); ok {
+		return fmter.AppendIdent(b, name), nil
+	}
+	return appendOperand(fmter, b, col)
+}
+
+// getJSONPath builds a "$.key[0]" path as used by MySQL, SQLite and SQL Server.
+// Path elements made of digits are array indexes.
+func getJSONPath(path []string) string {
+	var sb strings.Builder
+	sb.WriteByte('$')
+	for _, key := range path {
+		if _, err := strconv.Atoi(key); err == nil {
+			sb.WriteString("[" + key + "]")
+		} else if jsonPathKey.MatchString(key) {
+			sb.WriteString("." + key)
+		} else {
+			sb.WriteString(`."` + strings.ReplaceAll(key, `"`, `\"`) + `"`)
+		}
+	}
+	return sb.String()
+}
+
+// appendPGJSONPath appends the postgres "->" operators for the path, using "->>"
+// for the last element when text is set.
+func appendPGJSONPath(fmter schema.QueryGen, b []byte, path []string, text bool) []byte {
+	for i, key := range path {
+		op := " -> ?"
+		if text && i == len(path)-1 {
+			op = " ->> ?"
+		}
+		if idx, err := strconv.Atoi(key); err == nil {
+			b = fmter.AppendQuery(b, op, idx)
+		} else {
+			b = fmter.AppendQuery(b, op, key)
+		}
+	}
+	return b
+}
+
+func appendJSONFunc(fmter schema.QueryGen, b []byte, open string, col any, path []string, close string) ([]byte, error) {
+	b = append(b, open...)
+	b, err := appendColumn(fmter, b, col)
+	if err != nil {
+		return nil, err
+	}
+	b = fmter.AppendQuery(b, ", ?", getJSONPath(path))
+	return append(b, close...), nil
+}
+
+// JSONPath extracts the value at path from a JSON column as text. The column is a
+// column name, a Column reference or any other appender.
+func JSONPath(col any, path ...string) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		switch name := fmter.Dialect().Name(); name {
+		case dialect.PG:
+			b = append(b, '(')
+			b, err := appendColumn(fmter, b, col)
+			if err != nil {
+				return nil, err
+			}
+			b = appendPGJSONPath(fmter, b, path, true)
+			return append(b, ')'), nil
+		case dialect.SQLite:
+			return appendJSONFunc(fmter, b, "json_extract(", col, path, ")")
+		case dialect.MySQL:
+			return appendJSONFunc(fmter, b, "JSON_UNQUOTE(JSON_EXTRACT(", col, path, "))")
+		case dialect.MSSQL:
+			return appendJSONFunc(fmter, b, "JSON_VALUE(", col, path, ")")
+		default:
+			return nil, fmt.Errorf("JSONPath: dialect %s is not supported", name)
+		}
+	}
+}
+
+// JSONContains matches rows whose JSON column contains value, following the
+// semantics of the postgres @> operator. Postgres columns must be jsonb. SQLite has no
+// containment operator, so objects are compared leaf by leaf and arrays by membership
+// of their scalar elements.
+func JSONContains(col any, value any) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		doc, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		switch name := fmter.Dialect().Name(); name {
+		case dialect.PG:
+			if b, err = appendColumn(fmter, b, col); err != nil {
+				return nil, err
+			}
+			return fmter.AppendQuery(b, " @> ?::jsonb", string(doc)), nil
+		case dialect.MySQL:
+			b = append(b, "JSON_CONTAINS("...)
+			if b, err = appendColumn(fmter, b, col); err != nil {
+				return nil, err
+			}
+			return fmter.AppendQuery(b, ", ?)", string(doc)), nil
+		case dialect.SQLite:
+			var generic any
+			if err := json.Unmarshal(doc, &generic); err != nil {
+				return nil, err
+			}
+			b = append(b, '(')
+			if b, err = appendSQLiteContains(fmter, b, col, nil, generic); err != nil {
+				return nil, err
+			}
+			return append(b, ')'), nil
+		default:
+			return nil, fmt.Errorf("JSONContains: dialect %s is not supported", name)
+		}
+	}
+}
+
+func appendSQLiteContains(fmter schema.QueryGen, b []byte, col any, path []string, value any) ([]byte, error) {
+	var err error
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			return appendSQLiteType(fmter, b, col, path, "object")
+		}
+		for i, key := range slices.Sorted(maps.Keys(v)) {
+			if i > 0 {
+				b = append(b, " AND "...)
+			}
+			if b, err = appendSQLiteContains(fmter, b, col, append(slices.Clip(path), key), v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []any:
+		if len(v) == 0 {
+			return appendSQLiteType(fmter, b, col, path, "array")
+		}
+		for i, elem := range v {
+			if i > 0 {
+				b = append(b, " AND "...)
+			}
+			if b, err = appendSQLiteMember(fmter, b, col, path, elem); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		if b, err = JSONPath(col, path...).AppendQuery(fmter, b); err != nil {
+			return nil, err
+		}
+		return appendSQLiteScalar(fmter, b, " = ", v)
+	}
+}
+
+func appendSQLiteType(fmter schema.QueryGen, b []byte, col any, path []string, typ string) ([]byte, error) {
+	b = append(b, "json_type("...)
+	b, err := appendColumn(fmter, b, col)
+	if err != nil {
+		return nil, err
+	}
+	return fmter.AppendQuery(b, ", ?) = ?", getJSONPath(path), typ), nil
+}
+
+func appendSQLiteMember(fmter schema.QueryGen, b []byte, col any, path []string, value any) ([]byte, error) {
+	switch value.(type) {
+	case map[string]any, []any:
+		return nil, fmt.Errorf("nested %T array elements are not supported by sqlite", value)
+	}
+	b = append(b, "EXISTS (SELECT 1 FROM json_each("...)
+	b, err := appendColumn(fmter, b, col)
+	if err != nil {
+		return nil, err
+	}
+	b = fmter.AppendQuery(b, ", ?) WHERE value", getJSONPath(path))
+	if b, err = appendSQLiteScalar(fmter, b, " = ", value); err != nil {
+		return nil, err
+	}
+	return append(b, ')'), nil
+}
+
+// appendSQLiteScalar compares with a decoded JSON scalar the way json_extract returns
+// it, which is 1 and 0 for booleans.
+func appendSQLiteScalar(fmter schema.QueryGen, b []byte, op string, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, " IS NULL"...), nil
+	case bool:
+		if v {
+			return append(append(b, op...), '1'), nil
+		}
+		return append(append(b, op...), '0'), nil
+	default:
+		return fmter.AppendQuery(append(b, op...), "?", v), nil
+	}
+}
+
+// JSONHasElement matches rows whose JSON array, at path within the column, has value
+// as one of its elements.
+func JSONHasElement(col any, value any, path ...string) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		switch name := fmter.Dialect().Name(); name {
+		case dialect.PG:
+			elem, err := json.Marshal([]any{value})
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, '(')
+			if b, err = appendColumn(fmter, b, col); err != nil {
+				return nil, err
+			}
+			b = appendPGJSONPath(fmter, b, path, false)
+			return fmter.AppendQuery(b, ") @> ?::jsonb", string(elem)), nil
+		case dialect.MySQL:
+			elem, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, "JSON_CONTAINS("...)
+			if b, err = appendColumn(fmter, b, col); err != nil {
+				return nil, err
+			}
+			return fmter.AppendQuery(b, ", ?, ?)", string(elem), getJSONPath(path)), nil
+		case dialect.SQLite:
+			return appendSQLiteMember(fmter, b, col, path, value)
+		case dialect.MSSQL:
+			b = append(b, "EXISTS (SELECT 1 FROM OPENJSON("...)
+			b, err := appendColumn(fmter, b, col)
+			if err != nil {
+				return nil, err
+			}
+			return fmter.AppendQuery(b, ", ?) WHERE value = ?)", getJSONPath(path), value), nil
+		default:
+			return nil, fmt.Errorf("JSONHasElement: dialect %s is not supported", name)
+		}
+	}
+}
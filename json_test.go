@@ -0,0 +1,43 @@
+package bunquery_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/mmorton/bunquery"
+)
+
+func newQueryGen(name dialect.Name) schema.QueryGen {
+	return schema.NewQueryGen(namedDialect{Dialect: schema.NewNopQueryGen().Dialect(), name: name})
+}
+
+func TestJSON(t *testing.T) {
+	var tests = []struct {
+		dialect dialect.Name
+		expr    schema.QueryAppender
+		want    string
+	}{
+		{dialect.PG, bunquery.JSONPath("attrs", "tags", "0"), `("attrs" -> 'tags' ->> 0)`},
+		{dialect.SQLite, bunquery.JSONPath("attrs", "tags", "0"), `json_extract("attrs", '$.tags[0]')`},
+		{dialect.MySQL, bunquery.JSONPath("attrs", "a b"), `JSON_UNQUOTE(JSON_EXTRACT("attrs", '$."a b"'))`},
+		{dialect.MSSQL, bunquery.JSONPath("attrs", "a"), `JSON_VALUE("attrs", '$.a')`},
+		{dialect.PG, bunquery.JSONContains("attrs", map[string]any{"a": 1}), `"attrs" @> '{"a":1}'::jsonb`},
+		{dialect.MySQL, bunquery.JSONContains("attrs", map[string]any{"a": 1}), `JSON_CONTAINS("attrs", '{"a":1}')`},
+		{dialect.SQLite, bunquery.JSONContains("attrs", map[string]any{"a": true, "b": []any{"x"}}), `(json_extract("attrs", '$.a') = 1 AND EXISTS (SELECT 1 FROM json_each("attrs", '$.b') WHERE value = 'x'))`},
+		{dialect.PG, bunquery.JSONHasElement("attrs", "x", "tags"), `("attrs" -> 'tags') @> '["x"]'::jsonb`},
+		{dialect.MySQL, bunquery.JSONHasElement("attrs", "x", "tags"), `JSON_CONTAINS("attrs", '"x"', '$.tags')`},
+		{dialect.SQLite, bunquery.JSONHasElement("attrs", 2), `EXISTS (SELECT 1 FROM json_each("attrs", '$') WHERE value = 2)`},
+	}
+
+	for _, tt := range tests {
+		b, err := tt.expr.AppendQuery(newQueryGen(tt.dialect), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, string(b))
+	}
+
+	_, err := bunquery.JSONContains("attrs", 1).AppendQuery(newQueryGen(dialect.MSSQL), nil)
+	assert.Error(t, err)
+}
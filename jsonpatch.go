@@ -0,0 +1,382 @@
+package bunquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// ErrPatchTestFailed fails the update of a JSON patch whose test operations, or the
+// paths it removes or replaces, matched no row, as they are compiled into the WHERE clause.
+var ErrPatchTestFailed = errors.New("patch test failed")
+
+type JSONPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is a patch of a target model built from a JSON patch document, as defined
+// by RFC 6902. The add, remove, replace and test operations are supported. Operations
+// below a top-level field edit array and JSON columns in the database, where the
+// dialect allows it. Tests check the row as it was before the update, so a test can't
+// follow an operation that changes its path. Paths below a field that are removed or
+// replaced must exist, unless an earlier operation writes them. Patches with tests or
+// such paths must be applied to updates built by a MutationDB.
+type JSONPatch[Target any] struct {
+	target  *Target
+	ops     []JSONPatchOp
+	opts    *PatchOptions
+	changed []string
+}
+
+func NewJSONPatch[Target any](target *Target, doc []byte, opts ...PatchOption) (*JSONPatch[Target], error) {
+	var ops []JSONPatchOp
+	if err := json.Unmarshal(doc, &ops); err != nil {
+		return nil, fmt.Errorf("json patch: %w", err)
+	}
+	var written [][]string
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("json patch operation %d: %s requires a value", i, op.Op)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("json patch operation %d: %q is not supported", i, op.Op)
+		}
+
+		ptr, err := parseJSONPointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("json patch operation %d: %w", i, err)
+		} else if (op.Op == "remove" || op.Op == "replace") && ptr[len(ptr)-1] == "-" {
+			return nil, fmt.Errorf("json patch operation %d: %s of the end of an array", i, op.Op)
+		}
+		if op.Op == "test" {
+			if slices.ContainsFunc(written, func(path []string) bool { return isJSONPathPrefix(path, ptr) || isJSONPathPrefix(ptr, path) }) {
+				return nil, fmt.Errorf("json patch operation %d: test of %s follows an operation that changes it", i, op.Path)
+			}
+			continue
+		}
+		written = append(written, getJSONWrittenPath(op.Op, ptr))
+	}
+
+	return &JSONPatch[Target]{target: target, ops: ops, opts: newPatchOptions(opts...)}, nil
+}
+
+func (patch *JSONPatch[Target]) Target() *Target {
+	return patch.target
+}
+
+func (patch *JSONPatch[Target]) Changed() []string {
+	return patch.changed
+}
+
+// jsonEdit is an operation below a top-level field, applied by the database.
+type jsonEdit struct {
+	op    string
+	path  []string
+	value json.RawMessage
+}
+
+func (patch *JSONPatch[Target]) Compile() func(*bun.UpdateQuery) *bun.UpdateQuery {
+	return func(query *bun.UpdateQuery) *bun.UpdateQuery {
+		ps := newPatchSet(query, patch.Target(), patch.opts)
+		fields := getJSONPatchFields(ps.table)
+
+		var order []*schema.Field
+		edits := map[*schema.Field][]jsonEdit{}
+		replaced := map[*schema.Field]bool{}
+		var written [][]string
+
+		for i, op := range patch.ops {
+			ptr, err := parseJSONPointer(op.Path)
+			if err != nil {
+				query.Err(fmt.Errorf("json patch operation %d: %w", i, err))
+				return query
+			}
+			field, ok := fields[ptr[0]]
+			if !ok {
+				query.Err(fmt.Errorf("json patch path %q does not map to a patchable field of %s", op.Path, ps.table.TypeName))
+				return query
+			}
+
+			switch {
+			case op.Op == "test":
+				guard, err := getJSONPatchTest(ps.table, field, ptr[1:], op.Value)
+				if err == nil {
+					_, err = guard.AppendQuery(query.DB().QueryGen(), nil)
+				}
+				if err != nil {
+					query.Err(fmt.Errorf("json patch operation %d: %w", i, err))
+					return query
+				}
+				ps.query = ps.query.Where("?", guard)
+				ps.tested = true
+			case len(ptr) == 1:
+				delete(edits, field)
+				replaced[field] = true
+				if op.Op == "remove" || bytes.Equal(bytes.TrimSpace(op.Value), []byte("null")) {
+					ps.setNull(field)
+					continue
+				}
+				value, err := decodeFieldValue(field, op.Value)
+				if err != nil {
+					query.Err(fmt.Errorf("json patch operation %d: %w", i, err))
+					return query
+				}
+				ps.set(field, value)
+			case replaced[field]:
+				query.Err(fmt.Errorf("json patch operation %d: %s was replaced by an earlier operation", i, ptr[0]))
+				return query
+			default:
+				if op.Op != "add" && !slices.ContainsFunc(written, func(path []string) bool {
+					return isJSONPathPrefix(path, ptr) || isJSONPathPrefix(ptr, path)
+				}) {
+					// The path must exist in the row as it was before the update.
+					guard := getJSONPathExists(field, ptr[1:])
+					if _, err := guard.AppendQuery(query.DB().QueryGen(), nil); err != nil {
+						query.Err(fmt.Errorf("json patch operation %d: %w", i, err))
+						return query
+					}
+					ps.query = ps.query.Where("?", guard)
+					ps.tested = true
+				}
+				if _, ok := edits[field]; !ok {
+					order = append(order, field)
+				}
+				edits[field] = append(edits[field], jsonEdit{op: op.Op, path: ptr[1:], value: op.Value})
+			}
+			written = append(written, getJSONWrittenPath(op.Op, ptr))
+		}
+
+		for _, field := range order {
+			if fieldEdits, ok := edits[field]; ok {
+				// Nested appender errors don't surface from the query, so check them here.
+				if _, err := appendJSONEdits(query.DB().QueryGen(), nil, field, fieldEdits); err != nil {
+					query.Err(err)
+					return query
+				}
+				ps.setExpr(field, QueryAppenderFunc(func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+					return appendJSONEdits(fmter, b, field, fieldEdits)
+				}))
+			}
+		}
+
+		patch.changed = ps.changed
+		return ps.finish()
+	}
+}
+
+// getJSONWrittenPath returns the path an operation changes. Inserts and removals of
+// array elements move the elements that follow, so they change the array.
+func getJSONWrittenPath(op string, ptr []string) []string {
+	if op != "replace" && len(ptr) > 1 && isJSONIndex(ptr[len(ptr)-1]) {
+		return ptr[:len(ptr)-1]
+	}
+	return ptr
+}
+
+func isJSONPathPrefix(prefix, path []string) bool {
+	return len(prefix) <= len(path) && slices.Equal(prefix, path[:len(prefix)])
+}
+
+// parseJSONPointer splits an RFC 6901 JSON pointer into its unescaped tokens.
+func parseJSONPointer(ptr string) ([]string, error) {
+	if !strings.HasPrefix(ptr, "/") || ptr == "/" {
+		return nil, fmt.Errorf("invalid path %q", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func decodeFieldValue(field *schema.Field, raw json.RawMessage) (reflect.Value, error) {
+	value := reflect.New(field.StructField.Type)
+	if err := json.Unmarshal(raw, value.Interface()); err != nil {
+		return value, fmt.Errorf("%s: %w", field.GoName, err)
+	}
+	return value, nil
+}
+
+// getJSONPatchTest builds the WHERE guard of a test operation. Below a top-level field
+// the value is tested by JSON containment, which can't address array elements.
+func getJSONPatchTest(table *schema.Table, field *schema.Field, path []string, raw json.RawMessage) (schema.QueryAppender, error) {
+	if len(path) == 0 {
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			return schema.SafeQuery("? IS NULL", []any{bun.Ident(field.Name)}), nil
+		}
+		value, err := decodeFieldValue(field, raw)
+		if err != nil {
+			return nil, err
+		}
+		strct := reflect.New(table.Type).Elem()
+		assignPatchValue(field.Value(strct), value)
+		return schema.SafeQuery("? = ?", []any{bun.Ident(field.Name), getPatchValue(field, strct)}), nil
+	}
+
+	var doc any
+	if err := decodeJSON(raw, &doc); err != nil {
+		return nil, err
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		if isJSONIndex(path[i]) {
+			return nil, fmt.Errorf("test of array element %s is not supported", strings.Join(path[:i+1], "/"))
+		}
+		doc = map[string]any{path[i]: doc}
+	}
+	return JSONContains(bun.Ident(field.Name), doc), nil
+}
+
+// getJSONPathExists builds the WHERE guard that a path below a field exists.
+func getJSONPathExists(field *schema.Field, path []string) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		switch name := fmter.Dialect().Name(); {
+		case name == dialect.PG && field.Tag.HasOption("array"):
+			idx, err := strconv.Atoi(path[0])
+			if err != nil || len(path) != 1 || idx < 0 {
+				return nil, fmt.Errorf("%s: invalid array index %q", field.GoName, strings.Join(path, "/"))
+			}
+			return fmter.AppendQuery(b, "cardinality(?) > ?", bun.Ident(field.Name), idx), nil
+		case name == dialect.PG:
+			tokens := make([]any, len(path))
+			for i, token := range path {
+				tokens[i] = token
+			}
+			col := QueryAppenderFunc(func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+				return appendJSONEdits(fmter, b, field, nil)
+			})
+			return fmter.AppendQuery(b, "(? #> ARRAY[?]::text[]) IS NOT NULL", col, listAppender(tokens)), nil
+		case name == dialect.SQLite:
+			return fmter.AppendQuery(b, "json_type(?, ?) IS NOT NULL", bun.Ident(field.Name), getJSONPath(path)), nil
+		case name == dialect.MySQL:
+			return fmter.AppendQuery(b, "JSON_CONTAINS_PATH(?, 'one', ?)", bun.Ident(field.Name), getJSONPath(path)), nil
+		default:
+			return nil, fmt.Errorf("json patch: dialect %s is not supported", name)
+		}
+	}
+}
+
+func listAppender(values []any) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		return appendOperands(fmter, b, ", ", values)
+	}
+}
+
+func isJSONIndex(token string) bool {
+	_, err := strconv.Atoi(token)
+	return err == nil || token == "-"
+}
+
+// appendJSONEdits appends the column wrapped in a function call per edit.
+func appendJSONEdits(fmter schema.QueryGen, b []byte, field *schema.Field, edits []jsonEdit) ([]byte, error) {
+	name := fmter.Dialect().Name()
+	if len(edits) == 0 {
+		b = fmter.AppendIdent(b, field.Name)
+		if name == dialect.PG && !field.Tag.HasOption("array") {
+			b = append(b, "::jsonb"...)
+		}
+		return b, nil
+	}
+
+	edit := edits[len(edits)-1]
+	inner := QueryAppenderFunc(func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		return appendJSONEdits(fmter, b, field, edits[:len(edits)-1])
+	})
+	last := edit.path[len(edit.path)-1]
+	value := string(edit.value)
+
+	switch {
+	case name == dialect.PG && field.Tag.HasOption("array"):
+		return appendPGArrayEdit(fmter, b, field, inner, edit)
+	case name == dialect.PG:
+		path := make([]any, len(edit.path))
+		for i, token := range edit.path {
+			if token == "-" {
+				token = "-1"
+			}
+			path[i] = token
+		}
+		switch {
+		case edit.op == "remove":
+			return fmter.AppendQuery(b, "(? #- ARRAY[?]::text[])", inner, listAppender(path)), nil
+		case edit.op == "add" && isJSONIndex(last):
+			return fmter.AppendQuery(b, "jsonb_insert(?, ARRAY[?]::text[], ?::jsonb, ?)", inner, listAppender(path), value, last == "-"), nil
+		default:
+			return fmter.AppendQuery(b, "jsonb_set(?, ARRAY[?]::text[], ?::jsonb)", inner, listAppender(path), value), nil
+		}
+	case name == dialect.SQLite:
+		switch {
+		case edit.op == "remove":
+			return fmter.AppendQuery(b, "json_remove(?, ?)", inner, getJSONPath(edit.path)), nil
+		case edit.op == "add" && last == "-":
+			return fmter.AppendQuery(b, "json_insert(?, ?, json(?))", inner, getJSONPath(edit.path[:len(edit.path)-1])+"[#]", value), nil
+		case edit.op == "add" && isJSONIndex(last):
+			return nil, errors.New("inserting into a JSON array is not supported by sqlite")
+		default:
+			return fmter.AppendQuery(b, "json_set(?, ?, json(?))", inner, getJSONPath(edit.path), value), nil
+		}
+	case name == dialect.MySQL:
+		switch {
+		case edit.op == "remove":
+			return fmter.AppendQuery(b, "JSON_REMOVE(?, ?)", inner, getJSONPath(edit.path)), nil
+		case edit.op == "add" && last == "-":
+			return fmter.AppendQuery(b, "JSON_ARRAY_APPEND(?, ?, CAST(? AS JSON))", inner, getJSONPath(edit.path[:len(edit.path)-1]), value), nil
+		case edit.op == "add" && isJSONIndex(last):
+			return fmter.AppendQuery(b, "JSON_ARRAY_INSERT(?, ?, CAST(? AS JSON))", inner, getJSONPath(edit.path), value), nil
+		default:
+			return fmter.AppendQuery(b, "JSON_SET(?, ?, CAST(? AS JSON))", inner, getJSONPath(edit.path), value), nil
+		}
+	default:
+		return nil, fmt.Errorf("json patch: dialect %s is not supported", name)
+	}
+}
+
+// appendPGArrayEdit edits an element of a postgres array. Indexes are zero-based as in
+// JSON, while postgres slices are one-based.
+func appendPGArrayEdit(fmter schema.QueryGen, b []byte, field *schema.Field, inner schema.QueryAppender, edit jsonEdit) ([]byte, error) {
+	if len(edit.path) != 1 {
+		return nil, fmt.Errorf("%s is an array, path %s is too deep", field.GoName, strings.Join(edit.path, "/"))
+	}
+
+	var elem any
+	if edit.op != "remove" {
+		value := reflect.New(field.StructField.Type.Elem())
+		if err := json.Unmarshal(edit.value, value.Interface()); err != nil {
+			return nil, fmt.Errorf("%s: %w", field.GoName, err)
+		}
+		elem = value.Elem().Interface()
+	}
+
+	if edit.path[0] == "-" {
+		if edit.op != "add" {
+			return nil, fmt.Errorf("%s: %s of the end of an array", field.GoName, edit.op)
+		}
+		return fmter.AppendQuery(b, "array_append(?, ?)", inner, elem), nil
+	}
+
+	idx, err := strconv.Atoi(edit.path[0])
+	if err != nil || idx < 0 {
+		return nil, fmt.Errorf("%s: invalid array index %q", field.GoName, edit.path[0])
+	}
+	switch edit.op {
+	case "add":
+		return fmter.AppendQuery(b, "array_cat(array_append((?)[:?], ?), (?)[?:])", inner, idx, elem, inner, idx+1), nil
+	case "remove":
+		return fmter.AppendQuery(b, "array_cat((?)[:?], (?)[?:])", inner, idx, inner, idx+2), nil
+	default:
+		return fmter.AppendQuery(b, "array_cat(array_append((?)[:?], ?), (?)[?:])", inner, idx, elem, inner, idx+2), nil
+	}
+}
@@ -0,0 +1,136 @@
+package bunquery
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// MaskPatch is a patch that sets exactly the columns named by an update mask from a
+// source struct, as in AIP-134. Zero values are set as they are and nil pointers set
+// NULL. Paths are the JSON or Go names of source fields, and "*" names every mutable
+// field the source shares with the target.
+type MaskPatch[Target any, Source any] struct {
+	target  *Target
+	source  *Source
+	paths   []string
+	opts    *PatchOptions
+	changed []string
+}
+
+func NewMaskPatch[Target any, Source any](target *Target, source *Source, paths []string, opts ...PatchOption) *MaskPatch[Target, Source] {
+	return &MaskPatch[Target, Source]{target: target, source: source, paths: paths, opts: newPatchOptions(opts...)}
+}
+
+func (patch *MaskPatch[Target, Source]) Target() *Target {
+	return patch.target
+}
+
+func (patch *MaskPatch[Target, Source]) Changed() []string {
+	return patch.changed
+}
+
+func (patch *MaskPatch[Target, Source]) Compile() func(*bun.UpdateQuery) *bun.UpdateQuery {
+	return func(query *bun.UpdateQuery) *bun.UpdateQuery {
+		srcValue := reflect.ValueOf(patch.source).Elem()
+		if srcValue.Kind() != reflect.Struct {
+			query.Err(fmt.Errorf("source must be a struct"))
+			return query
+		}
+
+		ps := newPatchSet(query, patch.Target(), patch.opts)
+		fields, err := getMaskFields(ps.table, srcValue.Type(), patch.paths, ps.immutable)
+		if err != nil {
+			query.Err(err)
+			return query
+		}
+
+		for _, field := range fields {
+			value, err := srcValue.FieldByIndexErr(field.index)
+			if err != nil {
+				// A nil embedded pointer holds no values.
+				ps.setNull(field.target)
+			} else if opt, ok := value.Interface().(patchOptional); ok {
+				if value, state := opt.patchState(); state == optionalValue {
+					ps.set(field.target, value)
+				} else {
+					ps.setNull(field.target)
+				}
+			} else if value.Kind() == reflect.Pointer && value.IsNil() && field.target.StructField.Type.Kind() != reflect.Pointer {
+				ps.setNull(field.target)
+			} else {
+				ps.set(field.target, value)
+			}
+		}
+
+		patch.changed = ps.changed
+		return ps.finish()
+	}
+}
+
+type maskField struct {
+	index  []int
+	target *schema.Field
+}
+
+// getMaskFields maps mask paths to source fields, including promoted ones, and the
+// target fields they set, which are matched as for Patch. A "*" path leaves out the
+// immutable fields, while naming one fails the patch.
+func getMaskFields(table *schema.Table, srcType reflect.Type, paths []string, immutable []string) ([]maskField, error) {
+	var res []maskField
+	seen := map[string]bool{}
+	add := func(field maskField) {
+		if !seen[field.target.Name] {
+			seen[field.target.Name] = true
+			res = append(res, field)
+		}
+	}
+
+	visible := reflect.VisibleFields(srcType)
+	for _, path := range paths {
+		if path == "*" {
+			for _, sf := range visible {
+				if field, ok := getMaskField(table, sf); ok && !slices.Contains(immutable, field.target.GoName) {
+					add(field)
+				}
+			}
+			continue
+		}
+
+		idx := slices.IndexFunc(visible, func(sf reflect.StructField) bool {
+			name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			return !sf.Anonymous && (name == path || sf.Name == path)
+		})
+		if idx == -1 {
+			return nil, fmt.Errorf("update mask path %q does not map to a field of %s", path, srcType.Name())
+		}
+		field, ok := getMaskField(table, visible[idx])
+		if !ok {
+			return nil, fmt.Errorf("update mask path %q does not map to a patchable field of %s", path, table.TypeName)
+		}
+		add(field)
+	}
+	return res, nil
+}
+
+func getMaskField(table *schema.Table, sf reflect.StructField) (maskField, bool) {
+	if !sf.IsExported() || sf.Anonymous {
+		return maskField{}, false
+	}
+	name := sf.Tag.Get("bunpatch")
+	if name == "-" {
+		return maskField{}, false
+	} else if name == "" {
+		name = sf.Name
+	}
+	for _, field := range table.DataFields {
+		if field.GoName == name && field.StructField.Tag.Get("bunpatch") != "-" {
+			return maskField{index: sf.Index, target: field}, true
+		}
+	}
+	return maskField{}, false
+}
@@ -0,0 +1,143 @@
+package bunquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// MergePatch is a patch of a target model built from a JSON merge patch document, as
+// defined by RFC 7396. Absent keys leave their column alone and null sets it to NULL.
+// Objects are merged into the current value of the target field, so the target must
+// hold the row as it is stored.
+type MergePatch[Target any] struct {
+	target  *Target
+	doc     map[string]json.RawMessage
+	opts    *PatchOptions
+	changed []string
+}
+
+func NewMergePatch[Target any](target *Target, doc []byte, opts ...PatchOption) (*MergePatch[Target], error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, fmt.Errorf("merge patch: %w", err)
+	} else if fields == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	return &MergePatch[Target]{target: target, doc: fields, opts: newPatchOptions(opts...)}, nil
+}
+
+func (patch *MergePatch[Target]) Target() *Target {
+	return patch.target
+}
+
+func (patch *MergePatch[Target]) Changed() []string {
+	return patch.changed
+}
+
+func (patch *MergePatch[Target]) Compile() func(*bun.UpdateQuery) *bun.UpdateQuery {
+	return func(query *bun.UpdateQuery) *bun.UpdateQuery {
+		ps := newPatchSet(query, patch.Target(), patch.opts)
+		fields := getJSONPatchFields(ps.table)
+
+		for _, key := range slices.Sorted(maps.Keys(patch.doc)) {
+			field, ok := fields[key]
+			if !ok {
+				query.Err(fmt.Errorf("merge patch key %q does not map to a patchable field of %s", key, ps.table.TypeName))
+				return query
+			}
+
+			raw := bytes.TrimSpace(patch.doc[key])
+			switch {
+			case bytes.Equal(raw, []byte("null")):
+				ps.setNull(field)
+				continue
+			case len(raw) > 0 && raw[0] == '{':
+				merged, err := mergeJSONValue(field.Value(ps.target), raw)
+				if err != nil {
+					query.Err(fmt.Errorf("merge patch key %q: %w", key, err))
+					return query
+				}
+				raw = merged
+			}
+
+			value := reflect.New(field.StructField.Type)
+			if err := json.Unmarshal(raw, value.Interface()); err != nil {
+				query.Err(fmt.Errorf("merge patch key %q: %w", key, err))
+				return query
+			}
+			ps.set(field, value)
+		}
+
+		patch.changed = ps.changed
+		return ps.finish()
+	}
+}
+
+// getJSONPatchFields maps the JSON names of the target fields to the fields that a
+// patch document may set. Primary keys and fields tagged `bunpatch:"-"` can't be set.
+func getJSONPatchFields(table *schema.Table) map[string]*schema.Field {
+	res := make(map[string]*schema.Field, len(table.Fields))
+	for _, field := range table.Fields {
+		if field.IsPK || field.StructField.Tag.Get("bunpatch") == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.StructField.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.GoName
+		}
+		res[name] = field
+	}
+	return res
+}
+
+// mergeJSONValue merges the patch object into the JSON form of the current value.
+func mergeJSONValue(current reflect.Value, patch []byte) ([]byte, error) {
+	var target, doc any
+	if cur, err := json.Marshal(current.Interface()); err != nil {
+		return nil, err
+	} else if err := decodeJSON(cur, &target); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(patch, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeJSON(target, doc))
+}
+
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// mergeJSON applies a merge patch to a decoded JSON document as RFC 7396 describes.
+func mergeJSON(target any, patch any) any {
+	obj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	res, ok := target.(map[string]any)
+	if !ok {
+		res = map[string]any{}
+	}
+	for key, value := range obj {
+		if value == nil {
+			delete(res, key)
+		} else {
+			res[key] = mergeJSON(res[key], value)
+		}
+	}
+	return res
+}
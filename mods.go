@@ -29,6 +29,19 @@ func (m QueryMods) Use(mods ...QueryMod) QueryMods {
 	return slices.Collect(maps.Values(kinds))
 }
 
+func (m QueryMods) Has(kind string) bool {
+	return slices.ContainsFunc(m, func(mod QueryMod) bool { return mod.Kind() == kind })
+}
+
+func (m QueryMods) Without(kinds ...string) QueryMods {
+	if len(kinds) == 0 {
+		return m
+	}
+	return slices.DeleteFunc(slices.Clone(m), func(mod QueryMod) bool {
+		return slices.Contains(kinds, mod.Kind())
+	})
+}
+
 func (m QueryMods) Bind(ctx context.Context, db bun.IDB, qry QueryBuilderEx, args ...any) {
 	for _, mod := range m {
 		mod.Bind(ctx, db, qry, args...)
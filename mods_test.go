@@ -0,0 +1,106 @@
+package bunquery_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+
+	"github.com/mmorton/bunquery"
+)
+
+func nopMod(kind string) bunquery.QueryMod {
+	return bunquery.NewQueryMod(kind, func(ctx context.Context, iDB bun.IDB, query bunquery.QueryBuilderEx, args ...any) {})
+}
+
+func TestWithoutMods(t *testing.T) {
+	var bypassed []string
+	ctx := bunquery.NewContext(context.Background(), nil, nopMod("security"), nopMod("tenant"))
+	ctx, err := bunquery.UseBypassGuard(ctx, bunquery.NewBypassGuard(
+		func(ctx context.Context, kind string) error {
+			if kind == "tenant" {
+				return errors.New("not an admin")
+			}
+			return nil
+		},
+		func(ctx context.Context, kind string) {
+			bypassed = append(bypassed, kind)
+		},
+	))
+	assert.NoError(t, err)
+
+	ctx, err = bunquery.UseContextWithoutMods(ctx, "security", "unknown")
+	assert.NoError(t, err)
+	_, mods, _ := bunquery.FromContext(ctx)
+	assert.False(t, mods.Has("security"))
+	assert.True(t, mods.Has("tenant"))
+	assert.Equal(t, []string{"security"}, bypassed)
+
+	err = bunquery.UseQuery(ctx, func(ctx context.Context, db bunquery.QueryDB) error {
+		return nil
+	}, bunquery.WithoutMods("tenant"))
+	var bypassErr *bunquery.BypassError
+	assert.ErrorAs(t, err, &bypassErr)
+	assert.Equal(t, "tenant", bypassErr.Kind)
+}
+
+func TestWithoutModsStaysRemoved(t *testing.T) {
+	var bound []string
+	recordMod := func(kind string) bunquery.QueryMod {
+		return bunquery.NewQueryMod(kind, func(ctx context.Context, iDB bun.IDB, query bunquery.QueryBuilderEx, args ...any) {
+			bound = append(bound, kind)
+		})
+	}
+	allowAll := bunquery.NewBypassGuard(nil, nil)
+
+	db := newSQLiteDB(t)
+	ctx := bunquery.NewContext(context.Background(), db, recordMod("security"))
+	ctx, err := bunquery.UseBypassGuard(ctx, allowAll)
+	assert.NoError(t, err)
+	ctx, err = bunquery.UseContextWithoutMods(ctx, "security", "tenant")
+	assert.NoError(t, err)
+
+	// A definition that uses the bypassed kinds does not bring them back.
+	assert.NoError(t, bunquery.UseQuery(ctx, func(ctx context.Context, db bunquery.QueryDB) error {
+		db.NewSelect()
+		return nil
+	}, bunquery.WithMods(recordMod("security"), recordMod("tenant"), recordMod("audit"))))
+	assert.Equal(t, []string{"audit"}, bound)
+}
+
+func TestNewContextDropsBypasses(t *testing.T) {
+	var bound []string
+	securityMod := bunquery.NewQueryMod("security", func(ctx context.Context, iDB bun.IDB, query bunquery.QueryBuilderEx, args ...any) {
+		bound = append(bound, "security")
+	})
+
+	db := newSQLiteDB(t)
+	ctx := bunquery.NewContext(context.Background(), db, securityMod)
+	ctx, err := bunquery.UseBypassGuard(ctx, bunquery.NewBypassGuard(nil, nil))
+	assert.NoError(t, err)
+	ctx, err = bunquery.UseContextWithoutMods(ctx, "security")
+	assert.NoError(t, err)
+
+	// A new context neither keeps the bypass nor the guard.
+	ctx = bunquery.NewContext(ctx, db, securityMod)
+	assert.NoError(t, bunquery.UseQuery(ctx, func(ctx context.Context, db bunquery.QueryDB) error {
+		db.NewSelect()
+		return nil
+	}))
+	assert.Equal(t, []string{"security"}, bound)
+	_, err = bunquery.UseContextWithoutMods(ctx, "security")
+	assert.ErrorIs(t, err, bunquery.ErrNoBypassGuard)
+}
+
+func TestWithoutModsNeedsGuard(t *testing.T) {
+	ctx := bunquery.NewContext(context.Background(), nil, nopMod("security"))
+	_, err := bunquery.UseContextWithoutMods(ctx, "security")
+	assert.ErrorIs(t, err, bunquery.ErrNoBypassGuard)
+
+	err = bunquery.UseQuery(ctx, func(ctx context.Context, db bunquery.QueryDB) error {
+		return nil
+	}, bunquery.WithoutMods("security"))
+	assert.ErrorIs(t, err, bunquery.ErrNoBypassGuard)
+}
@@ -12,12 +12,20 @@ type MutationDB interface {
 	NewInsert() *bun.InsertQuery
 	NewUpdate(bindArgs ...any) *bun.UpdateQuery
 	NewDelete(bindArgs ...any) *bun.DeleteQuery
+	// NewMerge, NewTruncateTable and NewCreateTable fail when the RawPolicy denies
+	// unfiltered statements for the active mods.
+	NewMerge() *bun.MergeQuery
+	NewTruncateTable() *bun.TruncateTableQuery
+	NewCreateTable() *bun.CreateTableQuery
 }
 
 type wrapMutationDB struct {
-	ctx  context.Context
-	tx   bun.Tx
-	mods QueryMods
+	ctx   context.Context
+	tx    bun.Tx
+	name  string
+	mods  QueryMods
+	raw   RawPolicy
+	state *mutationState
 }
 
 var _ MutationDB = (*wrapMutationDB)(nil)
@@ -27,19 +35,63 @@ func (mut wrapMutationDB) Unwrap() bun.IDB {
 }
 
 func (mut wrapMutationDB) NewSelect(bindArgs ...any) *bun.SelectQuery {
-	return applyQueryMods(mut.ctx, mut.tx, mut.mods, mut.tx.NewSelect(), bindArgs...)
+	qry := applyQueryMods(mut.ctx, mut.tx, mut.mods, mut.tx.NewSelect(), bindArgs...)
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, nil, qry)
+}
+
+func (mut wrapMutationDB) NewValues(model any) *bun.ValuesQuery {
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, nil, mut.tx.NewValues(model))
+}
+
+func (mut wrapMutationDB) NewRaw(query string, args ...any) *bun.RawQuery {
+	qry := mut.tx.NewRaw(query, args...)
+	if err := mut.raw(mut.ctx, mut.mods); err != nil {
+		return qry.Err(err)
+	}
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, nil, qry)
+}
+
+// Relation is called by the package level Relation.
+func (mut wrapMutationDB) Relation(name string, apply ...func(*bun.SelectQuery) *bun.SelectQuery) func(*bun.SelectQuery) *bun.SelectQuery {
+	return applyRelationMods(mut.ctx, mut.tx, mut.mods, name, apply...)
 }
 
 func (mut wrapMutationDB) NewInsert() *bun.InsertQuery {
-	return mut.tx.NewInsert()
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, mut.state, mut.tx.NewInsert())
 }
 
 func (mut wrapMutationDB) NewUpdate(bindArgs ...any) *bun.UpdateQuery {
-	return applyQueryMods(mut.ctx, mut.tx, mut.mods, mut.tx.NewUpdate(), bindArgs...)
+	qry := applyQueryMods(mut.ctx, mut.tx, mut.mods, mut.tx.NewUpdate(), bindArgs...)
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, mut.state, qry)
 }
 
 func (mut wrapMutationDB) NewDelete(bindArgs ...any) *bun.DeleteQuery {
-	return applyQueryMods(mut.ctx, mut.tx, mut.mods, mut.tx.NewDelete(), bindArgs...)
+	qry := applyQueryMods(mut.ctx, mut.tx, mut.mods, mut.tx.NewDelete(), bindArgs...)
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, mut.state, qry)
+}
+
+func (mut wrapMutationDB) NewMerge() *bun.MergeQuery {
+	qry := mut.tx.NewMerge()
+	if err := mut.raw(mut.ctx, mut.mods); err != nil {
+		return qry.Err(err)
+	}
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, mut.state, qry)
+}
+
+func (mut wrapMutationDB) NewTruncateTable() *bun.TruncateTableQuery {
+	qry := mut.tx.NewTruncateTable()
+	if err := mut.raw(mut.ctx, mut.mods); err != nil {
+		return qry.Err(err)
+	}
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, nil, qry)
+}
+
+func (mut wrapMutationDB) NewCreateTable() *bun.CreateTableQuery {
+	qry := mut.tx.NewCreateTable()
+	if err := mut.raw(mut.ctx, mut.mods); err != nil {
+		return qry.Err(err)
+	}
+	return applyQueryConn(mut.ctx, mut.tx, mut.name, mut.mods, nil, qry)
 }
 
 func UseMutation(ctx context.Context, fn func(ctx context.Context, db MutationDB) error, opts ...AnyOpt) error {
@@ -49,10 +101,18 @@ func UseMutation(ctx context.Context, fn func(ctx context.Context, db MutationDB
 	}
 
 	opt := NewMutationOpts(opts...)
+	mods, err := dbCtx.resolveMods(ctx, &opt.QueryOpts)
+	if err != nil {
+		return err
+	}
 	mDB := wrapMutationDB{
-		ctx:  ctx,
-		mods: dbCtx.mods.Use(opt.Mods...),
+		ctx:   ctx,
+		name:  opt.Name,
+		mods:  mods,
+		raw:   guardRawPolicy(opt.RawPolicy, dbCtx.guard),
+		state: &mutationState{},
 	}
+	defer mDB.state.release()
 
 	weOwnTx := false
 
@@ -64,11 +124,14 @@ func UseMutation(ctx context.Context, fn func(ctx context.Context, db MutationDB
 	} else {
 		mDB.tx = tx
 		// Since we created a new Tx, create a new query context so Tx can be passed through.
-		ctx = createDbCtx(ctx, mDB.tx, mDB.mods)
+		ctx = dbCtx.with(ctx, mDB.tx, mDB.mods)
 		weOwnTx = true
 	}
 
-	err := fn(ctx, mDB)
+	err = fn(ctx, mDB)
+	if err == nil {
+		err = mDB.state.flush()
+	}
 
 	if weOwnTx {
 		if err != nil {
@@ -88,6 +151,7 @@ func UseMutation(ctx context.Context, fn func(ctx context.Context, db MutationDB
 }
 
 type Mutation[In any] struct {
+	Name      string
 	Args      func(args In) (In, error)
 	Handler   func(ctx context.Context, db MutationDB, args In) error
 	Use       []QueryMod
@@ -95,6 +159,7 @@ type Mutation[In any] struct {
 }
 
 type MutationEx[In any, Ext any] struct {
+	Name      string
 	Args      func(args In) (In, error)
 	Handler   func(ctx context.Context, db MutationDB, args In, ext Ext) error
 	Use       []QueryMod
@@ -110,7 +175,7 @@ func CreateMutation[In any](def Mutation[In]) func(ctx context.Context, args In)
 		}
 		return UseMutation(ctx, func(ctx context.Context, db MutationDB) error {
 			return def.Handler(ctx, db, args)
-		}, WithMods(def.Use...))
+		}, WithName(def.Name), WithMods(def.Use...))
 	}
 }
 
@@ -123,11 +188,12 @@ func CreateMutationEx[In any, Ext any](def MutationEx[In, Ext]) func(ctx context
 		}
 		return UseMutation(ctx, func(ctx context.Context, db MutationDB) error {
 			return def.Handler(ctx, db, args, ext)
-		}, WithMods(def.Use...))
+		}, WithName(def.Name), WithMods(def.Use...))
 	}
 }
 
 type QueryMutation[In any, Out any] struct {
+	Name      string
 	Args      func(args In) (In, error)
 	Handler   func(ctx context.Context, db MutationDB, args In) (Out, error)
 	Use       []QueryMod
@@ -135,6 +201,7 @@ type QueryMutation[In any, Out any] struct {
 }
 
 type QueryMutationEx[In any, Out any, Ext any] struct {
+	Name      string
 	Args      func(args In) (In, error)
 	Handler   func(ctx context.Context, db MutationDB, args In, ext Ext) (Out, error)
 	Use       []QueryMod
@@ -152,7 +219,7 @@ func CreateQueryMutation[In any, Out any](def QueryMutation[In, Out]) func(ctx c
 		return res, UseMutation(ctx, func(ctx context.Context, db MutationDB) error {
 			res, err = def.Handler(ctx, db, args)
 			return err
-		}, WithMods(def.Use...))
+		}, WithName(def.Name), WithMods(def.Use...))
 	}
 }
 
@@ -167,6 +234,6 @@ func CreateQueryMutationEx[In any, Out any, Ext any](def QueryMutationEx[In, Out
 		return res, UseMutation(ctx, func(ctx context.Context, db MutationDB) error {
 			res, err = def.Handler(ctx, db, args, ext)
 			return err
-		}, WithMods(def.Use...))
+		}, WithName(def.Name), WithMods(def.Use...))
 	}
 }
@@ -0,0 +1,92 @@
+package bunquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+type optionalState uint8
+
+const (
+	optionalUnset optionalState = iota
+	optionalNull
+	optionalValue
+)
+
+// Optional is a tri-state patch field that is either unset, null or holds a value. Its
+// zero value is unset, which is also what an absent JSON key decodes to, and a JSON
+// null decodes to null. Tag fields with `json:",omitzero"` to leave unset fields out
+// when encoding.
+type Optional[T any] struct {
+	value T
+	state optionalState
+}
+
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{value: value, state: optionalValue}
+}
+
+func Null[T any]() Optional[T] {
+	return Optional[T]{state: optionalNull}
+}
+
+func (o Optional[T]) IsSet() bool {
+	return o.state != optionalUnset
+}
+
+func (o Optional[T]) IsNull() bool {
+	return o.state == optionalNull
+}
+
+func (o Optional[T]) IsZero() bool {
+	return o.state == optionalUnset
+}
+
+// Get returns the value and whether there is one, which is false when unset or null.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.state == optionalValue
+}
+
+func (o *Optional[T]) Set(value T) {
+	o.value, o.state = value, optionalValue
+}
+
+func (o *Optional[T]) SetNull() {
+	var zero T
+	o.value, o.state = zero, optionalNull
+}
+
+func (o *Optional[T]) Unset() {
+	var zero T
+	o.value, o.state = zero, optionalUnset
+}
+
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if o.state != optionalValue {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		o.SetNull()
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Set(value)
+	return nil
+}
+
+// patchOptional lets Patch.Compile read an Optional of any type.
+type patchOptional interface {
+	patchState() (reflect.Value, optionalState)
+}
+
+func (o Optional[T]) patchState() (reflect.Value, optionalState) {
+	return reflect.ValueOf(&o.value).Elem(), o.state
+}
@@ -3,7 +3,10 @@ package bunquery
 import "database/sql"
 
 type QueryOpts struct {
-	Mods []QueryMod
+	Name      string
+	Mods      []QueryMod
+	Without   []string
+	RawPolicy RawPolicy
 }
 
 type QueryOpt func(*QueryOpts)
@@ -17,12 +20,30 @@ func (opt QueryOpt) Apply(opts any) {
 	}
 }
 
+func WithName(name string) QueryOpt {
+	return func(o *QueryOpts) {
+		o.Name = name
+	}
+}
+
 func WithMods(mods ...QueryMod) QueryOpt {
 	return func(o *QueryOpts) {
 		o.Mods = append(o.Mods, mods...)
 	}
 }
 
+func WithoutMods(kinds ...string) QueryOpt {
+	return func(o *QueryOpts) {
+		o.Without = append(o.Without, kinds...)
+	}
+}
+
+func WithRawPolicy(policy RawPolicy) QueryOpt {
+	return func(o *QueryOpts) {
+		o.RawPolicy = policy
+	}
+}
+
 type MutationOpts struct {
 	QueryOpts
 	TxOptions *sql.TxOptions
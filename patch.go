@@ -1,15 +1,24 @@
 package bunquery
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/schema"
 )
 
 type Patch[Target any, Derived any] struct {
 	target  *Target
 	derived *Derived
+	opts    *PatchOptions
+	changed []string
 }
 
 func (patch *Patch[Target, Derived]) Target() *Target {
@@ -20,47 +29,482 @@ type ResourcePatcher[Resource any] interface {
 	Target() *Resource
 }
 
-func CreatePatch[Target any, Derived any](target *Target, derived *Derived) Patch[Target, Derived] {
+// Changed returns the Go names of the fields changed by the last Compile.
+func (patch *Patch[Target, Derived]) Changed() []string {
+	return patch.changed
+}
+
+var ErrImmutableField = errors.New("field is immutable")
+
+type PatchOptions struct {
+	SyncTarget bool
+	Immutable  []string
+}
+
+type PatchOption = func(*PatchOptions)
+
+// WithSyncTarget updates the target once the patch was executed and changed its row.
+// Where the dialect supports RETURNING, the updated row is scanned back into the target,
+// so database defaults and triggers are reflected. Otherwise the patched fields are
+// copied to the target, which requires an update built by a MutationDB.
+func WithSyncTarget() PatchOption {
+	return func(opts *PatchOptions) {
+		opts.SyncTarget = true
+	}
+}
+
+// WithImmutableFields refuses patches that set any of the target fields, given by their
+// Go names.
+func WithImmutableFields(fields ...string) PatchOption {
+	return func(opts *PatchOptions) {
+		opts.Immutable = append(opts.Immutable, fields...)
+	}
+}
+
+func newPatchOptions(opts ...PatchOption) *PatchOptions {
+	res := &PatchOptions{}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+func CreatePatch[Target any, Derived any](target *Target, derived *Derived, opts ...PatchOption) Patch[Target, Derived] {
 	return Patch[Target, Derived]{
 		target:  target,
 		derived: derived,
+		opts:    newPatchOptions(opts...),
 	}
 }
 
-func (patch *Patch[Target, Derived]) Compile() func(*bun.UpdateQuery) *bun.UpdateQuery {
-	ourType := reflect.TypeOf(patch)
-	return func(query *bun.UpdateQuery) *bun.UpdateQuery {
-		drvValue := reflect.ValueOf(patch.derived).Elem()
-		drvType := drvValue.Type()
+// patchField maps a field of the derived struct to the target column it sets.
+type patchField struct {
+	index  int
+	target *schema.Field
+}
 
-		if drvType.Kind() != reflect.Struct {
-			query.Err(fmt.Errorf("derived must be a struct"))
-			return query
+// getPatchFields maps the derived fields to target fields by their Go name, or by the
+// target field named in a `bunpatch:"Field"` tag. Fields tagged `bunpatch:"-"` are skipped.
+func getPatchFields(table *schema.Table, drvType reflect.Type, skip reflect.Type) ([]patchField, error) {
+	var res []patchField
+	for i := 0; i < drvType.NumField(); i++ {
+		field := drvType.Field(i)
+		if field.Type == skip || !field.IsExported() || field.Type.Implements(childChangeSetType) {
+			continue
+		}
+		name := field.Tag.Get("bunpatch")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
 		}
 
-		query = query.Model(patch.Target()).WherePK()
+		idx := slices.IndexFunc(table.Fields, func(f *schema.Field) bool { return f.GoName == name })
+		if idx == -1 {
+			return nil, fmt.Errorf("patch field %s.%s does not map to a column of %s", drvType.Name(), field.Name, table.TypeName)
+		}
+		res = append(res, patchField{index: i, target: table.Fields[idx]})
+	}
+	return res, nil
+}
 
-		for i := 0; i < drvType.NumField(); i++ {
-			field := drvType.Field(i)
-			if field.Type == ourType.Elem() {
-				continue
+// assignPatchValue sets dst to src, dereferencing src when dst is not a pointer.
+func assignPatchValue(dst, src reflect.Value) bool {
+	if src.Kind() == reflect.Pointer && !src.Type().AssignableTo(dst.Type()) {
+		src = src.Elem()
+	}
+	if !src.Type().AssignableTo(dst.Type()) {
+		return false
+	}
+	dst.Set(src)
+	return true
+}
+
+// getPatchValue formats the value with the appender of the target field, so tags such
+// as type:json apply as they would for the target model.
+func getPatchValue(field *schema.Field, strct reflect.Value) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		return field.AppendValue(fmter, b, strct), nil
+	}
+}
+
+// patchSet adds the SET clauses of a patch to an update of the target model, keeping
+// track of the changed fields and the changes reported to mutation hooks.
+type patchSet struct {
+	query     *bun.UpdateQuery
+	table     *schema.Table
+	target    reflect.Value
+	sync      bool
+	immutable []string
+	changes   map[string]any
+	changed   []string
+	syncs     []func()        // copy the patched values to the target
+	exprs     []*schema.Field // fields set by expressions, read back after the update
+	read      reflect.Value   // the row holding the values of exprs once read back
+	tested    bool            // the update is guarded by tests and must change its row
+	err       error
+}
+
+func newPatchSet(query *bun.UpdateQuery, target any, opts *PatchOptions) *patchSet {
+	if opts == nil {
+		opts = &PatchOptions{}
+	}
+	ps := &patchSet{
+		query:     query.Model(target),
+		table:     query.DB().Dialect().Tables().Get(reflect.TypeOf(target)),
+		immutable: opts.Immutable,
+		changes:   make(map[string]any),
+	}
+	if v := reflect.ValueOf(target); v.IsNil() {
+		// A nil target patches the rows selected by the caller, which aren't synced.
+		ps.target = reflect.New(v.Type().Elem()).Elem()
+	} else {
+		ps.query = ps.query.WherePK()
+		ps.target = v.Elem()
+		ps.sync = opts.SyncTarget
+	}
+	return ps
+}
+
+// mutable fails the query when field is immutable.
+func (ps *patchSet) mutable(field *schema.Field) bool {
+	if slices.Contains(ps.immutable, field.GoName) {
+		ps.err = fmt.Errorf("%w: %s.%s", ErrImmutableField, ps.table.TypeName, field.GoName)
+		ps.query = ps.query.Err(ps.err)
+		return false
+	}
+	return true
+}
+
+// set sets the column of field to value, which is of the field type or a pointer to it.
+func (ps *patchSet) set(field *schema.Field, value reflect.Value) {
+	if !ps.mutable(field) {
+		return
+	}
+	col := field.Name
+
+	strct := reflect.New(ps.table.Type).Elem()
+	if !assignPatchValue(field.Value(strct), value) {
+		// Leave values of other types to the driver, they can't be compared or synced.
+		ps.changed = append(ps.changed, field.GoName)
+		ps.query = ps.query.Set("? = ?", bun.Ident(col), value.Interface())
+		ps.changes[col] = value.Interface()
+		return
+	}
+
+	ps.compare(field, field.Value(strct))
+	ps.query = ps.query.Set("? = ?", bun.Ident(col), getPatchValue(field, strct))
+	ps.changes[col] = value.Interface()
+}
+
+// setNull sets the column of field to NULL, and the target field to its zero value.
+func (ps *patchSet) setNull(field *schema.Field) {
+	if !ps.mutable(field) {
+		return
+	}
+	ps.compare(field, reflect.Zero(field.StructField.Type))
+	ps.query = ps.query.Set("? = NULL", bun.Ident(field.Name))
+	ps.changes[field.Name] = nil
+}
+
+// setExpr sets the column of field to an expression that is evaluated by the database,
+// so its value is only known once it was read back.
+func (ps *patchSet) setExpr(field *schema.Field, expr schema.QueryAppender) {
+	if !ps.mutable(field) {
+		return
+	}
+	ps.changed = append(ps.changed, field.GoName)
+	ps.exprs = append(ps.exprs, field)
+	ps.query = ps.query.Set("? = ?", bun.Ident(field.Name), expr)
+	ps.changes[field.Name] = nil
+}
+
+func (ps *patchSet) compare(field *schema.Field, newValue reflect.Value) {
+	if curValue := field.Value(ps.target); !reflect.DeepEqual(curValue.Interface(), newValue.Interface()) {
+		ps.changed = append(ps.changed, field.GoName)
+		if ps.sync {
+			ps.syncs = append(ps.syncs, func() { curValue.Set(newValue) })
+		}
+	}
+}
+
+func (ps *patchSet) finish() *bun.UpdateQuery {
+	event, ok := lookupMutationEvent(ps.query)
+	switch {
+	case !ok && ps.tested:
+		ps.query = ps.query.Err(errors.New("patch tests require an update built by a MutationDB"))
+	case ps.tested || ps.sync && !ps.query.DB().HasFeature(feature.Returning):
+		if !ok {
+			ps.query = ps.query.Err(errors.New("syncing the target requires RETURNING or an update built by a MutationDB"))
+		} else {
+			event.afterExec = append(event.afterExec, ps.afterExec)
+		}
+	case ps.sync:
+		ps.query = ps.query.Returning("*")
+	}
+
+	if ok {
+		event.Changes = ps.changes
+		if len(ps.exprs) > 0 {
+			event.beforeAfter = append(event.beforeAfter, ps.readExprs)
+		}
+	}
+
+	return ps.query
+}
+
+// readExprs reads the columns set by expressions back once the update changed its row,
+// so the changes hold their values.
+func (ps *patchSet) readExprs(ctx context.Context, db bun.IDB, res sql.Result) error {
+	if res == nil {
+		// The rows were scanned by RETURNING, into the target when it is synced.
+		if ps.sync {
+			for _, field := range ps.exprs {
+				ps.changes[field.Name] = field.Value(ps.target).Interface()
+			}
+		}
+		return nil
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	row := reflect.New(ps.table.Type)
+	for _, field := range ps.table.PKs {
+		field.Value(row.Elem()).Set(field.Value(ps.target))
+	}
+	cols := make([]string, len(ps.exprs))
+	for i, field := range ps.exprs {
+		cols[i] = field.Name
+	}
+	if err := db.NewSelect().Model(row.Interface()).Column(cols...).WherePK().Scan(ctx); err != nil {
+		return err
+	}
+	for _, field := range ps.exprs {
+		ps.changes[field.Name] = field.Value(row.Elem()).Interface()
+	}
+	ps.read = row.Elem()
+	return nil
+}
+
+// afterExec fails the update when its tests matched no row, and syncs the target when
+// the update changed its row.
+func (ps *patchSet) afterExec(ctx context.Context, db bun.IDB, res sql.Result) error {
+	if res == nil {
+		// The rows were scanned into a destination of the caller.
+		if ps.tested {
+			return errors.New("patch tests can't be checked for updates that return rows")
+		}
+		return nil
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		if ps.tested {
+			return ErrPatchTestFailed
+		}
+		return nil
+	}
+	if !ps.sync {
+		return nil
+	}
+	for _, fn := range ps.syncs {
+		fn()
+	}
+	for _, field := range ps.exprs {
+		field.Value(ps.target).Set(field.Value(ps.read))
+	}
+	return nil
+}
+
+// apply sets the non-nil fields of the derived struct.
+func (patch *Patch[Target, Derived]) apply(ps *patchSet) error {
+	drvValue := reflect.ValueOf(patch.derived).Elem()
+	drvType := drvValue.Type()
+
+	if drvType.Kind() != reflect.Struct {
+		return fmt.Errorf("derived must be a struct")
+	}
+
+	fields, err := getPatchFields(ps.table, drvType, reflect.TypeFor[Patch[Target, Derived]]())
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		value := drvValue.Field(field.index)
+		if opt, ok := value.Interface().(patchOptional); ok {
+			switch value, state := opt.patchState(); state {
+			case optionalNull:
+				ps.setNull(field.target)
+			case optionalValue:
+				ps.set(field.target, value)
 			}
-			if field.Tag.Get("bunpatch") == "-" {
+			continue
+		}
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
 				continue
 			}
+		}
 
-			value := drvValue.Field(i)
-			if value.Kind() == reflect.Pointer {
-				if value.IsNil() {
-					continue
-				}
-			}
+		ps.set(field.target, value)
+	}
+	return nil
+}
+
+func (patch *Patch[Target, Derived]) Compile() func(*bun.UpdateQuery) *bun.UpdateQuery {
+	return func(query *bun.UpdateQuery) *bun.UpdateQuery {
+		if len(patch.children()) > 0 {
+			query.Err(errors.New("patch has child changes, apply it with Exec"))
+			return query
+		}
+
+		ps := newPatchSet(query, patch.Target(), patch.opts)
+		if err := patch.apply(ps); err != nil {
+			query.Err(err)
+			return query
+		}
+
+		patch.changed = ps.changed
+		return ps.finish()
+	}
+}
+
+// children returns the non-empty child change sets by the name of their relation.
+func (patch *Patch[Target, Derived]) children() map[string]childChangeSet {
+	res := map[string]childChangeSet{}
+	drvValue := reflect.ValueOf(patch.derived).Elem()
+	for i := 0; i < drvValue.NumField(); i++ {
+		field := drvValue.Type().Field(i)
+		if !field.IsExported() || !field.Type.Implements(childChangeSetType) {
+			continue
+		}
+		name := field.Tag.Get("bunpatch")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		if cs := drvValue.Field(i).Interface().(childChangeSet); !cs.empty() {
+			res[name] = cs
+		}
+	}
+	return res
+}
+
+// Exec updates the target and applies the child change sets of its has-many relations,
+// all in the transaction of db. The update is skipped when only children change. Children
+// of a target that is missing or hidden by the mods fail with sql.ErrNoRows.
+func (patch *Patch[Target, Derived]) Exec(ctx context.Context, db MutationDB) error {
+	query := db.NewUpdate()
+	ps := newPatchSet(query, patch.Target(), patch.opts)
+	if err := patch.apply(ps); err != nil {
+		return err
+	} else if ps.err != nil {
+		return ps.err
+	}
+	patch.changed = ps.changed
+
+	children := patch.children()
+	for name := range children {
+		if rel, ok := ps.table.Relations[name]; !ok || rel.Type != schema.HasManyRelation {
+			return fmt.Errorf("patch field %s does not map to a has-many relation of %s", name, ps.table.TypeName)
+		}
+	}
+
+	parent := true
+	if len(ps.changes) > 0 {
+		n, err := execRowsAffected(ps.finish().Exec(ctx))
+		if err != nil {
+			return err
+		}
+		parent = n == 1
+	} else if len(children) > 0 {
+		ok, err := db.NewSelect().Model(patch.Target()).WherePK().Exists(ctx)
+		if err != nil {
+			return err
+		}
+		parent = ok
+	}
+	if !parent && len(children) > 0 {
+		return fmt.Errorf("patched %s: %w", ps.table.TypeName, sql.ErrNoRows)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(children)) {
+		if err := children[name].apply(ctx, db, ps.table.Relations[name], ps.target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			col := PascalToDelimited(field.Name, "_")
+var ErrTooManyRows = errors.New("too many rows affected")
 
-			query = query.Set("? = ?", bun.Ident(col), value.Interface())
+type BulkOptions struct {
+	MaxRows int64
+}
+
+type BulkOption = func(*BulkOptions)
+
+// WithMaxRows fails a bulk patch that affects more than max rows. The update runs in a
+// savepoint that is then rolled back, so no rows are changed and the mutation may go on.
+func WithMaxRows(max int64) BulkOption {
+	return func(opts *BulkOptions) {
+		opts.MaxRows = max
+	}
+}
+
+// ExecBulk applies the patch to every row of the target table that filter selects,
+// instead of to the target, and returns the number of rows affected.
+func (patch *Patch[Target, Derived]) ExecBulk(ctx context.Context, db MutationDB, filter func(*bun.UpdateQuery) *bun.UpdateQuery, opts ...BulkOption) (int64, error) {
+	res := &BulkOptions{}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if len(patch.children()) > 0 {
+		return 0, errors.New("bulk patches can't have child changes")
+	}
+
+	ps := newPatchSet(db.NewUpdate(), (*Target)(nil), patch.opts)
+	if err := patch.apply(ps); err != nil {
+		return 0, err
+	} else if ps.err != nil {
+		return 0, ps.err
+	} else if len(ps.changes) == 0 {
+		return 0, ErrPatchEmpty
+	}
+	query := ps.finish().Apply(filter)
+
+	if res.MaxRows <= 0 {
+		return execRowsAffected(query.Exec(ctx))
+	}
+
+	tx, ok := db.Unwrap().(bun.Tx)
+	if !ok {
+		return 0, errors.New("bulk patches with a row limit require a transaction")
+	}
+	sp, err := tx.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	n, err := execRowsAffected(query.Exec(ctx))
+	if err == nil && n > res.MaxRows {
+		err = fmt.Errorf("%w: %d rows, at most %d allowed", ErrTooManyRows, n, res.MaxRows)
+	}
+	if err != nil {
+		if rbErr := sp.Rollback(); rbErr != nil {
+			return 0, rbErr
 		}
+		return 0, err
+	}
+	return n, sp.Commit()
+}
 
-		return query
+func execRowsAffected(res sql.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
 	}
+	return res.RowsAffected()
 }
@@ -0,0 +1,593 @@
+package bunquery_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/mmorton/bunquery"
+)
+
+func newTestDB() *bun.DB {
+	return bun.NewDB(&sql.DB{}, namedDialect{Dialect: schema.NewNopQueryGen().Dialect(), name: dialect.SQLite})
+}
+
+type patchUser struct {
+	ID      int64 `bun:",pk"`
+	OwnerID int64
+	Name    string   `bun:"full_name"`
+	Emails  []string `bun:",type:json"`
+}
+
+type patchUserPatch struct {
+	bunquery.Patch[patchUser, patchUserPatch]
+	OwnerID     *int64
+	DisplayName *string `bunpatch:"Name"`
+	Emails      *[]string
+	Ignored     *string `bunpatch:"-"`
+}
+
+type badUserPatch struct {
+	bunquery.Patch[patchUser, badUserPatch]
+	Missing *string
+}
+
+func TestPatchColumns(t *testing.T) {
+	db := newTestDB()
+	user := &patchUser{ID: 1}
+
+	patch := &patchUserPatch{OwnerID: new(int64), DisplayName: new(string), Emails: &[]string{"a"}}
+	patch.Patch = bunquery.CreatePatch(user, patch)
+	assert.Equal(t,
+		`UPDATE "patch_users" SET "owner_id" = 0, "full_name" = '', "emails" = '["a"]' WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+
+	bad := &badUserPatch{}
+	bad.Patch = bunquery.CreatePatch(user, bad)
+	_, err := db.NewUpdate().Apply(bad.Compile()).AppendQuery(db.QueryGen(), nil)
+	assert.ErrorContains(t, err, "badUserPatch.Missing does not map to a column")
+}
+
+func TestPatchSyncTarget(t *testing.T) {
+	db := newTestDB()
+	user := &patchUser{ID: 1, OwnerID: 2, Name: "old"}
+
+	patch := &patchUserPatch{OwnerID: new(int64), DisplayName: new(string)}
+	*patch.OwnerID, *patch.DisplayName = 2, "new"
+	patch.Patch = bunquery.CreatePatch(user, patch, bunquery.WithSyncTarget())
+	assert.Equal(t,
+		`UPDATE "patch_users" SET "owner_id" = 2, "full_name" = 'new' WHERE ("id" = 1) RETURNING *`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+	assert.Equal(t, []string{"Name"}, patch.Changed())
+	// The target is synced once the update ran.
+	assert.Equal(t, "old", user.Name)
+}
+
+type versionedItemPatch struct {
+	bunquery.Patch[versionedItem, versionedItemPatch]
+	Title *string
+}
+
+type noReturningDialect struct {
+	schema.Dialect
+}
+
+func (d noReturningDialect) Features() feature.Feature {
+	return d.Dialect.Features() &^ feature.Returning
+}
+
+func TestPatchSyncTargetExec(t *testing.T) {
+	for _, returning := range []bool{true, false} {
+		t.Run(fmt.Sprintf("returning=%v", returning), func(t *testing.T) {
+			db := newSQLiteDB(t, (*versionedItem)(nil))
+			if !returning {
+				db = bun.NewDB(db.DB, noReturningDialect{db.Dialect()})
+			}
+			ctx := bunquery.NewContext(context.Background(), db, bunquery.NewVersionMod())
+			_, err := db.NewInsert().Model(&versionedItem{ID: 1, Title: "a"}).Exec(ctx)
+			assert.NoError(t, err)
+
+			apply := func(item *versionedItem, title string) error {
+				patch := &versionedItemPatch{Title: &title}
+				patch.Patch = bunquery.CreatePatch(item, patch, bunquery.WithSyncTarget())
+				return bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+					_, err := db.NewUpdate().Apply(patch.Compile()).Exec(ctx)
+					return err
+				})
+			}
+
+			item := &versionedItem{ID: 1, Title: "a"}
+			assert.NoError(t, apply(item, "b"))
+			assert.Equal(t, "b", item.Title)
+			assert.Equal(t, int64(1), item.Version)
+
+			// A stale update leaves the target as it was.
+			stale := &versionedItem{ID: 1, Title: "a"}
+			var staleErr *bunquery.ErrStaleObject
+			assert.ErrorAs(t, apply(stale, "c"), &staleErr)
+			assert.Equal(t, &versionedItem{ID: 1, Title: "a"}, stale)
+
+			// Mutations without hooks are synced too.
+			ctx = bunquery.NewContext(context.Background(), db)
+			assert.NoError(t, apply(item, "d"))
+			assert.Equal(t, "d", item.Title)
+		})
+	}
+
+	// Without RETURNING, only updates of a MutationDB can be synced.
+	db := bun.NewDB(&sql.DB{}, noReturningDialect{schema.NewNopQueryGen().Dialect()})
+	item := &versionedItem{ID: 1, Title: "a"}
+	patch := &versionedItemPatch{Title: new(string)}
+	patch.Patch = bunquery.CreatePatch(item, patch, bunquery.WithSyncTarget())
+	_, err := db.NewUpdate().Apply(patch.Compile()).Exec(context.Background())
+	assert.ErrorContains(t, err, "syncing the target requires RETURNING")
+}
+
+type bulkItem struct {
+	ID     int64 `bun:",pk"`
+	Status string
+}
+
+type bulkItemPatch struct {
+	bunquery.Patch[bulkItem, bulkItemPatch]
+	Status *string
+}
+
+func TestPatchExecBulk(t *testing.T) {
+	db := newSQLiteDB(t, (*bulkItem)(nil))
+	ctx := bunquery.NewContext(context.Background(), db)
+	_, err := db.NewInsert().Model(&[]bulkItem{{ID: 1, Status: "new"}, {ID: 2, Status: "new"}, {ID: 3, Status: "new"}}).Exec(ctx)
+	assert.NoError(t, err)
+	statuses := func() []string {
+		var res []string
+		assert.NoError(t, db.NewSelect().Model((*bulkItem)(nil)).Column("status").Order("id").Scan(ctx, &res))
+		return res
+	}
+	bulk := func(status string, filter func(*bun.UpdateQuery) *bun.UpdateQuery, opts ...bunquery.BulkOption) (n int64, err error) {
+		patch := &bulkItemPatch{Status: &status}
+		patch.Patch = bunquery.CreatePatch((*bulkItem)(nil), patch)
+		err = bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			n, err = patch.ExecBulk(ctx, db, filter, opts...)
+			return err
+		})
+		return n, err
+	}
+
+	n, err := bulk("done", func(q *bun.UpdateQuery) *bun.UpdateQuery { return q.Where("id < 3") }, bunquery.WithMaxRows(2))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, []string{"done", "done", "new"}, statuses())
+
+	n, err = bulk("all", func(q *bun.UpdateQuery) *bun.UpdateQuery { return q.Where("id > 0") }, bunquery.WithMaxRows(2))
+	assert.ErrorIs(t, err, bunquery.ErrTooManyRows)
+	assert.Zero(t, n)
+	assert.Equal(t, []string{"done", "done", "new"}, statuses())
+
+	// The mutation goes on after the rows were rolled back.
+	err = bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		status := "all"
+		patch := &bulkItemPatch{Status: &status}
+		patch.Patch = bunquery.CreatePatch((*bulkItem)(nil), patch)
+		if _, err := patch.ExecBulk(ctx, db, func(q *bun.UpdateQuery) *bun.UpdateQuery { return q.Where("id > 0") }, bunquery.WithMaxRows(1)); !errors.Is(err, bunquery.ErrTooManyRows) {
+			return err
+		}
+		_, err := db.NewUpdate().Model(&bulkItem{ID: 3, Status: "one"}).WherePK().Exec(ctx)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"done", "done", "one"}, statuses())
+
+	patch := &bulkItemPatch{}
+	patch.Patch = bunquery.CreatePatch((*bulkItem)(nil), patch)
+	err = bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := patch.ExecBulk(ctx, db, func(q *bun.UpdateQuery) *bun.UpdateQuery { return q.Where("id > 0") })
+		return err
+	})
+	assert.ErrorIs(t, err, bunquery.ErrPatchEmpty)
+
+	status := "immutable"
+	patch = &bulkItemPatch{Status: &status}
+	patch.Patch = bunquery.CreatePatch((*bulkItem)(nil), patch, bunquery.WithImmutableFields("Status"))
+	err = bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := patch.ExecBulk(ctx, db, func(q *bun.UpdateQuery) *bun.UpdateQuery { return q.Where("id > 0") }, bunquery.WithMaxRows(1))
+		return err
+	})
+	assert.ErrorIs(t, err, bunquery.ErrImmutableField)
+}
+
+type mergeDoc struct {
+	ID    int64          `bun:",pk"`
+	Title string         `json:"title"`
+	Note  *string        `json:"note"`
+	Attrs map[string]any `json:"attrs" bun:",type:json"`
+}
+
+func TestMergePatch(t *testing.T) {
+	db := newTestDB()
+	doc := &mergeDoc{ID: 1, Title: "a", Note: new(string), Attrs: map[string]any{"a": 1, "b": map[string]any{"c": 2}}}
+
+	patch, err := bunquery.NewMergePatch(doc, []byte(`{"note": null, "attrs": {"a": null, "b": {"d": 3}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`UPDATE "merge_docs" SET "attrs" = '{"b":{"c":2,"d":3}}', "note" = NULL WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+	assert.Equal(t, []string{"Attrs", "Note"}, patch.Changed())
+
+	patch, err = bunquery.NewMergePatch(doc, []byte(`{"ID": 2}`))
+	assert.NoError(t, err)
+	_, err = db.NewUpdate().Apply(patch.Compile()).AppendQuery(db.QueryGen(), nil)
+	assert.ErrorContains(t, err, `merge patch key "ID" does not map to a patchable field`)
+
+	_, err = bunquery.NewMergePatch(doc, []byte(`[]`))
+	assert.Error(t, err)
+}
+
+func TestJSONPatch(t *testing.T) {
+	db := newTestDB()
+	doc := &mergeDoc{ID: 1, Title: "a", Attrs: map[string]any{"tags": []any{"x"}}}
+
+	patch, err := bunquery.NewJSONPatch(doc, []byte(`[
+		{"op": "replace", "path": "/title", "value": "b"},
+		{"op": "remove", "path": "/note"},
+		{"op": "add", "path": "/attrs/tags/-", "value": "y"},
+		{"op": "add", "path": "/attrs/new", "value": 1},
+		{"op": "remove", "path": "/attrs/new"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`UPDATE "merge_docs" SET "title" = 'b', "note" = NULL, "attrs" = json_remove(json_set(json_insert("attrs", '$.tags[#]', json('"y"')), '$.new', json('1')), '$.new') WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+	assert.Equal(t, []string{"Title", "Attrs"}, patch.Changed())
+
+	// Tests, and paths that must exist, are checked once the update ran, which needs a MutationDB.
+	for _, ops := range []string{
+		`{"op": "test", "path": "/title", "value": "a"}`,
+		`{"op": "remove", "path": "/attrs/old"}`,
+		`{"op": "replace", "path": "/attrs/old", "value": 1}`,
+	} {
+		patch, err = bunquery.NewJSONPatch(doc, []byte("["+ops+"]"))
+		assert.NoError(t, err)
+		_, err = db.NewUpdate().Apply(patch.Compile()).AppendQuery(db.QueryGen(), nil)
+		assert.ErrorContains(t, err, "patch tests require an update built by a MutationDB", ops)
+	}
+	_, err = bunquery.NewJSONPatch(doc, []byte(`[{"op": "remove", "path": "/attrs/tags/-"}]`))
+	assert.ErrorContains(t, err, "remove of the end of an array")
+
+	for _, ops := range []string{
+		`{"op": "replace", "path": "/title", "value": "b"}, {"op": "test", "path": "/title", "value": "b"}`,
+		`{"op": "remove", "path": "/attrs/a"}, {"op": "test", "path": "/attrs", "value": {}}`,
+		`{"op": "replace", "path": "/attrs", "value": {}}, {"op": "test", "path": "/attrs/a", "value": 1}`,
+		`{"op": "add", "path": "/attrs/tags/0", "value": "y"}, {"op": "test", "path": "/attrs/tags/1", "value": "x"}`,
+	} {
+		_, err = bunquery.NewJSONPatch(doc, []byte("["+ops+"]"))
+		assert.ErrorContains(t, err, "follows an operation that changes it", ops)
+	}
+
+	patch, err = bunquery.NewJSONPatch(doc, []byte(`[{"op": "add", "path": "/attrs/tags/0", "value": "y"}]`))
+	assert.NoError(t, err)
+	_, err = db.NewUpdate().Apply(patch.Compile()).AppendQuery(db.QueryGen(), nil)
+	assert.Error(t, err)
+
+	_, err = bunquery.NewJSONPatch(doc, []byte(`[{"op": "copy", "path": "/title", "from": "/note"}]`))
+	assert.Error(t, err)
+}
+
+func TestJSONPatchTest(t *testing.T) {
+	db := newSQLiteDB(t, (*mergeDoc)(nil))
+	ctx := bunquery.NewContext(context.Background(), db)
+	_, err := db.NewInsert().Model(&mergeDoc{ID: 1, Title: "a", Attrs: map[string]any{"n": 1}}).Exec(ctx)
+	assert.NoError(t, err)
+
+	apply := func(doc *mergeDoc, ops string) error {
+		patch, err := bunquery.NewJSONPatch(doc, []byte(ops), bunquery.WithSyncTarget())
+		if err != nil {
+			return err
+		}
+		return bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewUpdate().Apply(patch.Compile()).Exec(ctx)
+			return err
+		})
+	}
+
+	doc := &mergeDoc{ID: 1, Title: "a", Attrs: map[string]any{"n": 1}}
+	err = apply(doc, `[{"op": "test", "path": "/title", "value": "b"}, {"op": "replace", "path": "/title", "value": "c"}]`)
+	assert.ErrorIs(t, err, bunquery.ErrPatchTestFailed)
+	assert.Equal(t, "a", doc.Title)
+
+	err = apply(doc, `[{"op": "test", "path": "/title", "value": "a"}, {"op": "replace", "path": "/title", "value": "c"}, {"op": "add", "path": "/attrs/m", "value": 2}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", doc.Title)
+	assert.Equal(t, map[string]any{"n": float64(1), "m": float64(2)}, doc.Attrs)
+
+	var stored mergeDoc
+	assert.NoError(t, db.NewSelect().Model(&stored).Where("id = 1").Scan(ctx))
+	assert.Equal(t, "c", stored.Title)
+
+	// Removing or replacing a missing path fails, unless an earlier operation adds it.
+	for _, ops := range []string{
+		`[{"op": "remove", "path": "/attrs/x"}]`,
+		`[{"op": "replace", "path": "/attrs/x", "value": 1}]`,
+	} {
+		assert.ErrorIs(t, apply(doc, ops), bunquery.ErrPatchTestFailed, ops)
+	}
+	assert.NoError(t, apply(doc, `[{"op": "add", "path": "/attrs/x", "value": 1}, {"op": "replace", "path": "/attrs/x", "value": 2}]`))
+	assert.NoError(t, apply(doc, `[{"op": "remove", "path": "/attrs/x"}]`))
+	assert.Equal(t, map[string]any{"n": float64(1), "m": float64(2)}, doc.Attrs)
+}
+
+// changesHook records the changes of every mutation.
+type changesHook struct {
+	bunquery.QueryMod
+	changes []map[string]any
+}
+
+func (h *changesHook) BeforeMutation(ctx context.Context, db bun.IDB, event *bunquery.MutationEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *changesHook) AfterMutation(ctx context.Context, db bun.IDB, event *bunquery.MutationEvent, res sql.Result) error {
+	h.changes = append(h.changes, event.Changes)
+	return nil
+}
+
+func TestJSONPatchChanges(t *testing.T) {
+	db := newSQLiteDB(t, (*mergeDoc)(nil))
+	hook := &changesHook{QueryMod: nopMod("changes")}
+	ctx := bunquery.NewContext(context.Background(), db, hook)
+	_, err := db.NewInsert().Model(&mergeDoc{ID: 1, Title: "a", Attrs: map[string]any{"n": 1}}).Exec(ctx)
+	assert.NoError(t, err)
+
+	// Edits below a field record the value of the field after the update.
+	for i, opts := range [][]bunquery.PatchOption{nil, {bunquery.WithSyncTarget()}} {
+		patch, err := bunquery.NewJSONPatch(&mergeDoc{ID: 1}, []byte(fmt.Sprintf(`[{"op": "add", "path": "/attrs/m", "value": %d}]`, i)), opts...)
+		assert.NoError(t, err)
+		assert.NoError(t, bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			_, err := db.NewUpdate().Apply(patch.Compile()).Exec(ctx)
+			return err
+		}))
+		assert.Equal(t, map[string]any{"attrs": map[string]any{"n": float64(1), "m": float64(i)}}, hook.changes[i])
+	}
+}
+
+type diffModel struct {
+	ID      int64 `bun:",pk"`
+	Name    string
+	Tags    []string `bun:",type:json"`
+	Seen    time.Time
+	Comment sql.NullString
+}
+
+func TestDiffPatch(t *testing.T) {
+	db := newTestDB()
+	now := time.Now()
+	before := &diffModel{ID: 1, Name: "a", Tags: []string{"x"}, Seen: now, Comment: sql.NullString{String: "stale"}}
+
+	after := *before
+	after.Tags = []string{"x"}
+	after.Seen = now.In(time.FixedZone("x", 3600))
+	after.Comment = sql.NullString{}
+	diff := bunquery.DiffPatch(before, &after)
+	assert.True(t, diff.Empty())
+	_, err := db.NewUpdate().Apply(diff.Compile()).AppendQuery(db.QueryGen(), nil)
+	assert.ErrorIs(t, err, bunquery.ErrPatchEmpty)
+
+	after.Name = "b"
+	after.Tags = append(after.Tags, "y")
+	diff = bunquery.DiffPatch(before, &after)
+	assert.Equal(t, []string{"Name", "Tags"}, diff.Changed())
+	assert.Equal(t,
+		`UPDATE "diff_models" SET "name" = 'b', "tags" = '["x","y"]' WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(diff.Compile()).String(),
+	)
+}
+
+type diffValues struct {
+	ID      int64 `bun:",pk"`
+	Note    *string
+	Deleted sql.NullTime
+	Attrs   map[string]any `bun:",type:json"`
+}
+
+func TestDiffPatchValues(t *testing.T) {
+	now := time.Now()
+	before := &diffValues{ID: 1, Deleted: sql.NullTime{Time: now, Valid: true}, Attrs: map[string]any{"a": 1}}
+
+	after := *before
+	after.Deleted.Time = now.UTC()
+	after.Attrs = map[string]any{"a": 1}
+	assert.True(t, bunquery.DiffPatch(before, &after).Empty())
+
+	after.Note = new(string)
+	after.Deleted.Valid = false
+	after.Attrs = map[string]any{"a": 2}
+	assert.Equal(t, []string{"Note", "Deleted", "Attrs"}, bunquery.DiffPatch(before, &after).Changed())
+}
+
+type maskSource struct {
+	Name    string  `json:"name"`
+	Comment *string `json:"comment"`
+	Owner   string  `json:"owner"`
+}
+
+func TestMaskPatch(t *testing.T) {
+	db := newTestDB()
+	target := &diffModel{ID: 1, Name: "a", Comment: sql.NullString{String: "c", Valid: true}}
+	source := &maskSource{}
+
+	patch := bunquery.NewMaskPatch(target, source, []string{"name", "Comment"})
+	assert.Equal(t,
+		`UPDATE "diff_models" SET "name" = '', "comment" = NULL WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+
+	patch = bunquery.NewMaskPatch(target, source, []string{"*"}, bunquery.WithImmutableFields("Comment"))
+	assert.Equal(t,
+		`UPDATE "diff_models" SET "name" = '' WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+
+	for _, tt := range []struct {
+		paths []string
+		err   string
+	}{
+		{[]string{"missing"}, `update mask path "missing" does not map to a field`},
+		{[]string{"owner"}, `update mask path "owner" does not map to a patchable field`},
+		{[]string{"comment"}, bunquery.ErrImmutableField.Error()},
+	} {
+		patch = bunquery.NewMaskPatch(target, source, tt.paths, bunquery.WithImmutableFields("Comment"))
+		_, err := db.NewUpdate().Apply(patch.Compile()).AppendQuery(db.QueryGen(), nil)
+		assert.ErrorContains(t, err, tt.err)
+	}
+}
+
+type optionalPatch struct {
+	bunquery.Patch[diffModel, optionalPatch]
+	Name    bunquery.Optional[string]         `json:"name,omitzero"`
+	Comment bunquery.Optional[sql.NullString] `json:"comment,omitzero"`
+	Tags    bunquery.Optional[[]string]       `json:"tags,omitzero"`
+}
+
+func TestOptionalPatch(t *testing.T) {
+	db := newTestDB()
+	target := &diffModel{ID: 1}
+
+	patch := &optionalPatch{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"name": "b", "comment": null}`), patch))
+	patch.Patch = bunquery.CreatePatch(target, patch)
+	assert.False(t, patch.Tags.IsSet())
+	assert.True(t, patch.Comment.IsNull())
+	assert.Equal(t,
+		`UPDATE "diff_models" SET "name" = 'b', "comment" = NULL WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+
+	b, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "b", "comment": null}`, string(b))
+}
+
+type childOrder struct {
+	ID    int64 `bun:",pk"`
+	Note  string
+	Owner string
+	Items []*childItem `bun:"rel:has-many,join:id=order_id"`
+}
+
+type childItem struct {
+	ID      int64 `bun:",pk,autoincrement"`
+	OrderID int64
+	Name    string
+}
+
+type childOrderPatch struct {
+	bunquery.Patch[childOrder, childOrderPatch]
+	Note  *string
+	Items bunquery.Children[childItem]
+}
+
+func TestChildrenPatch(t *testing.T) {
+	db := newTestDB()
+	order := &childOrder{ID: 1}
+
+	note := "a"
+	patch := &childOrderPatch{Note: &note}
+	patch.Patch = bunquery.CreatePatch(order, patch)
+	assert.Equal(t,
+		`UPDATE "child_orders" SET "note" = 'a' WHERE ("id" = 1)`,
+		db.NewUpdate().Apply(patch.Compile()).String(),
+	)
+
+	patch.Items.Delete = []*childItem{{ID: 2}}
+	_, err := db.NewUpdate().Apply(patch.Compile()).AppendQuery(db.QueryGen(), nil)
+	assert.ErrorContains(t, err, "apply it with Exec")
+}
+
+func TestChildrenPatchExec(t *testing.T) {
+	db := newSQLiteDB(t, (*childOrder)(nil), (*childItem)(nil))
+	ctx := bunquery.NewContext(context.Background(), db)
+	_, err := db.NewInsert().Model(&[]childOrder{{ID: 1}, {ID: 2}}).Exec(ctx)
+	assert.NoError(t, err)
+	_, err = db.NewInsert().Model(&[]childItem{{ID: 10, OrderID: 1, Name: "a"}, {ID: 20, OrderID: 2, Name: "b"}}).Exec(ctx)
+	assert.NoError(t, err)
+
+	apply := func(items bunquery.Children[childItem]) error {
+		patch := &childOrderPatch{Items: items}
+		patch.Patch = bunquery.CreatePatch(&childOrder{ID: 1}, patch)
+		return bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			return patch.Exec(ctx, db)
+		})
+	}
+	items := func() []childItem {
+		var res []childItem
+		assert.NoError(t, db.NewSelect().Model(&res).Order("id").Scan(ctx))
+		return res
+	}
+
+	// Updates, inserts without a key and inserts of new keys.
+	assert.NoError(t, apply(bunquery.Children[childItem]{
+		Upsert: []*childItem{{ID: 10, Name: "x"}, {Name: "y"}, {ID: 30, Name: "z"}},
+	}))
+	assert.Equal(t, []childItem{
+		{ID: 10, OrderID: 1, Name: "x"},
+		{ID: 20, OrderID: 2, Name: "b"},
+		{ID: 30, OrderID: 1, Name: "z"},
+		{ID: 31, OrderID: 1, Name: "y"},
+	}, items())
+	before := items()
+
+	// Children of another parent fail the patch, which changes nothing.
+	err = apply(bunquery.Children[childItem]{Upsert: []*childItem{{ID: 10, Name: "n"}, {ID: 20, Name: "n"}}})
+	assert.ErrorContains(t, err, "is not in Items of the patched childOrder")
+	err = apply(bunquery.Children[childItem]{Delete: []*childItem{{ID: 10}, {ID: 20}}})
+	assert.ErrorContains(t, err, "1 of the 2 ChildItem to delete are not in Items")
+	assert.Equal(t, before, items())
+
+	assert.NoError(t, apply(bunquery.Children[childItem]{Delete: []*childItem{{ID: 10}, {ID: 31}}}))
+	assert.Equal(t, []childItem{{ID: 20, OrderID: 2, Name: "b"}, {ID: 30, OrderID: 1, Name: "z"}}, items())
+}
+
+func TestChildrenPatchParent(t *testing.T) {
+	db := newSQLiteDB(t, (*childOrder)(nil), (*childItem)(nil))
+	_, err := db.NewInsert().Model(&[]childOrder{{ID: 1, Owner: "alice"}, {ID: 2, Owner: "bob"}}).Exec(context.Background())
+	assert.NoError(t, err)
+	ctx := bunquery.NewContext(context.Background(), db, bunquery.NewQueryMod("owner", func(ctx context.Context, iDB bun.IDB, query bunquery.QueryBuilderEx, args ...any) {
+		query.WhereTable(func(table *schema.Table) (string, []any) {
+			if _, ok := table.FieldMap["owner"]; ok {
+				return "?TableAlias.owner = 'alice'", nil
+			}
+			return "", nil
+		})
+	}))
+
+	apply := func(id int64, note *string) error {
+		patch := &childOrderPatch{Note: note, Items: bunquery.Children[childItem]{Upsert: []*childItem{{Name: "a"}}}}
+		patch.Patch = bunquery.CreatePatch(&childOrder{ID: id}, patch)
+		return bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			return patch.Exec(ctx, db)
+		})
+	}
+	note := "n"
+	assert.NoError(t, apply(1, nil))
+	assert.NoError(t, apply(1, &note))
+	assert.ErrorIs(t, apply(2, nil), sql.ErrNoRows)
+	assert.ErrorIs(t, apply(2, &note), sql.ErrNoRows)
+	assert.ErrorIs(t, apply(3, nil), sql.ErrNoRows)
+
+	n, err := db.NewSelect().Model((*childItem)(nil)).Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
@@ -9,12 +9,17 @@ import (
 type QueryDB interface {
 	Unwrap() bun.IDB
 	NewSelect(bindArgs ...any) *bun.SelectQuery
+	NewValues(model any) *bun.ValuesQuery
+	// NewRaw fails when the RawPolicy denies raw queries for the active mods.
+	NewRaw(query string, args ...any) *bun.RawQuery
 }
 
 type wrapQueryDB struct {
 	ctx  context.Context
 	db   bun.IDB
+	name string
 	mods QueryMods
+	raw  RawPolicy
 }
 
 var _ QueryDB = (*wrapQueryDB)(nil)
@@ -24,7 +29,25 @@ func (q wrapQueryDB) Unwrap() bun.IDB {
 }
 
 func (q wrapQueryDB) NewSelect(bindArgs ...any) *bun.SelectQuery {
-	return applyQueryMods(q.ctx, q.db, q.mods, q.db.NewSelect(), bindArgs...)
+	qry := applyQueryMods(q.ctx, q.db, q.mods, q.db.NewSelect(), bindArgs...)
+	return applyQueryConn(q.ctx, q.db, q.name, q.mods, nil, qry)
+}
+
+func (q wrapQueryDB) NewValues(model any) *bun.ValuesQuery {
+	return applyQueryConn(q.ctx, q.db, q.name, q.mods, nil, q.db.NewValues(model))
+}
+
+func (q wrapQueryDB) NewRaw(query string, args ...any) *bun.RawQuery {
+	qry := q.db.NewRaw(query, args...)
+	if err := q.raw(q.ctx, q.mods); err != nil {
+		return qry.Err(err)
+	}
+	return applyQueryConn(q.ctx, q.db, q.name, q.mods, nil, qry)
+}
+
+// Relation is called by the package level Relation.
+func (q wrapQueryDB) Relation(name string, apply ...func(*bun.SelectQuery) *bun.SelectQuery) func(*bun.SelectQuery) *bun.SelectQuery {
+	return applyRelationMods(q.ctx, q.db, q.mods, name, apply...)
 }
 
 func UseQuery(ctx context.Context, fn func(ctx context.Context, db QueryDB) error, opts ...AnyOpt) error {
@@ -33,10 +56,16 @@ func UseQuery(ctx context.Context, fn func(ctx context.Context, db QueryDB) erro
 		return ErrNoContext
 	}
 	opt := NewQueryOpts(opts...)
+	mods, err := dbCtx.resolveMods(ctx, opt)
+	if err != nil {
+		return err
+	}
 	qDB := wrapQueryDB{
 		ctx:  ctx,
 		db:   dbCtx.db,
-		mods: dbCtx.mods.Use(opt.Mods...),
+		name: opt.Name,
+		mods: mods,
+		raw:  guardRawPolicy(opt.RawPolicy, dbCtx.guard),
 	}
 	return fn(ctx, qDB)
 }
@@ -47,21 +76,29 @@ func UseQueryDB(ctx context.Context, opts ...AnyOpt) (QueryDB, error) {
 		return nil, ErrNoContext
 	}
 	opt := NewQueryOpts(opts...)
+	mods, err := dbCtx.resolveMods(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
 	qDB := wrapQueryDB{
 		ctx:  ctx,
 		db:   dbCtx.db,
-		mods: dbCtx.mods.Use(opt.Mods...),
+		name: opt.Name,
+		mods: mods,
+		raw:  guardRawPolicy(opt.RawPolicy, dbCtx.guard),
 	}
 	return qDB, nil
 }
 
 type Query[In any, Out any] struct {
+	Name    string
 	Args    func(args In) (In, error)
 	Handler func(ctx context.Context, db QueryDB, args In) (Out, error)
 	Use     []QueryMod
 }
 
 type QueryEx[In any, Out any, Ext any] struct {
+	Name    string
 	Args    func(args In) (In, error)
 	Handler func(ctx context.Context, db QueryDB, args In, ext Ext) (Out, error)
 	Use     []QueryMod
@@ -85,7 +122,7 @@ func CreateQuery[In any, Out any](def Query[In, Out]) func(ctx context.Context,
 		return res, UseQuery(ctx, func(ctx context.Context, db QueryDB) error {
 			res, err = def.Handler(ctx, db, args)
 			return err
-		}, WithMods(def.Use...))
+		}, WithName(def.Name), WithMods(def.Use...))
 	}
 }
 
@@ -100,6 +137,6 @@ func CreateQueryEx[In any, Out any, Ext any](def QueryEx[In, Out, Ext]) func(ctx
 		return res, UseQuery(ctx, func(ctx context.Context, db QueryDB) error {
 			res, err = def.Handler(ctx, db, args, ext)
 			return err
-		}, WithMods(def.Use...))
+		}, WithName(def.Name), WithMods(def.Use...))
 	}
 }
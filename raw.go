@@ -0,0 +1,80 @@
+package bunquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrRawDenied = errors.New("unfiltered query denied")
+
+// RestrictiveMod is implemented by mods, such as row security, whose filters can not
+// be enforced on raw SQL or other statements that bypass QueryMod.Bind. Mods that are
+// a MutationHook are restrictive unless they report otherwise, as raw statements skip
+// the hooks.
+type RestrictiveMod interface {
+	Restrictive() bool
+}
+
+func isRestrictive(mod QueryMod) bool {
+	if r, ok := mod.(RestrictiveMod); ok {
+		return r.Restrictive()
+	}
+	_, ok := mod.(MutationHook)
+	return ok
+}
+
+// RawPolicy decides whether raw and other unfiltered statements may be built while
+// the given mods are active. A policy that allows them while restrictive mods are
+// active bypasses those mods, so the BypassGuard must allow it.
+type RawPolicy func(ctx context.Context, mods QueryMods) error
+
+func AllowRaw(ctx context.Context, mods QueryMods) error {
+	return nil
+}
+
+func DenyRawWithRestrictiveMods(ctx context.Context, mods QueryMods) error {
+	for _, mod := range mods {
+		if isRestrictive(mod) {
+			return fmt.Errorf("%w: %q mod is active", ErrRawDenied, mod.Kind())
+		}
+	}
+	return nil
+}
+
+func DenyRawWith(kinds ...string) RawPolicy {
+	return func(ctx context.Context, mods QueryMods) error {
+		for _, mod := range mods {
+			if slices.Contains(kinds, mod.Kind()) {
+				return fmt.Errorf("%w: %q mod is active", ErrRawDenied, mod.Kind())
+			}
+		}
+		return nil
+	}
+}
+
+// guardRawPolicy routes the bypasses of policy through guard.
+func guardRawPolicy(policy RawPolicy, guard BypassGuard) RawPolicy {
+	if policy == nil {
+		return DenyRawWithRestrictiveMods
+	}
+	return func(ctx context.Context, mods QueryMods) error {
+		if err := policy(ctx, mods); err != nil {
+			return err
+		}
+		for _, mod := range mods {
+			if !isRestrictive(mod) {
+				continue
+			}
+			if guard == nil {
+				return &BypassError{Kind: mod.Kind(), Err: ErrNoBypassGuard}
+			}
+			if err := guard.AllowBypass(ctx, mod.Kind()); err != nil {
+				return &BypassError{Kind: mod.Kind(), Err: err}
+			}
+			guard.Bypassed(ctx, mod.Kind())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package bunquery_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/mmorton/bunquery"
+)
+
+type restrictiveMod struct {
+	bunquery.QueryMod
+}
+
+func (m restrictiveMod) Restrictive() bool { return true }
+
+func TestRawPolicy(t *testing.T) {
+	// sqlite has no MERGE, so the dialect claims it to reach the policy check.
+	db := newSQLiteDB(t)
+	db = bun.NewDB(db.DB, mergeDialect{db.Dialect()})
+	ctx := bunquery.NewContext(context.Background(), db, restrictiveMod{nopMod("security")})
+
+	t.Run("denied by default", func(t *testing.T) {
+		err := bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			for _, err := range []error{
+				db.NewRaw("SELECT 1").Scan(ctx, new(int)),
+				execErr(db.NewCreateTable().Model((*relationUser)(nil)).Exec(ctx)),
+				execErr(db.NewTruncateTable().Model((*relationUser)(nil)).Exec(ctx)),
+				execErr(db.NewMerge().Model((*relationUser)(nil)).Exec(ctx)),
+			} {
+				assert.ErrorIs(t, err, bunquery.ErrRawDenied)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("allowed without restrictive mods", func(t *testing.T) {
+		ctx := bunquery.NewContext(context.Background(), db, nopMod("security"))
+		var n int
+		err := bunquery.UseQuery(ctx, func(ctx context.Context, db bunquery.QueryDB) error {
+			return db.NewRaw("SELECT 1").Scan(ctx, &n)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("denied with mutation hooks", func(t *testing.T) {
+		ctx := bunquery.NewContext(context.Background(), db, bunquery.NewAuditMod())
+		err := bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			return db.NewRaw("SELECT 1").Scan(ctx, new(int))
+		})
+		assert.ErrorIs(t, err, bunquery.ErrRawDenied)
+
+		ctx = bunquery.NewContext(context.Background(), db, unrestrictiveHook{bunquery.NewAuditMod()})
+		err = bunquery.UseMutation(ctx, func(ctx context.Context, db bunquery.MutationDB) error {
+			return db.NewRaw("SELECT 1").Scan(ctx, new(int))
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("override needs a guard", func(t *testing.T) {
+		err := bunquery.UseQuery(ctx, func(ctx context.Context, db bunquery.QueryDB) error {
+			return db.NewRaw("SELECT 1").Scan(ctx, new(int))
+		}, bunquery.WithRawPolicy(bunquery.AllowRaw))
+		var bypassErr *bunquery.BypassError
+		assert.ErrorAs(t, err, &bypassErr)
+		assert.ErrorIs(t, err, bunquery.ErrNoBypassGuard)
+	})
+
+	t.Run("override goes through the guard", func(t *testing.T) {
+		var bypassed []string
+		ctx, err := bunquery.UseBypassGuard(ctx, bunquery.NewBypassGuard(
+			func(ctx context.Context, kind string) error {
+				if ctx.Value(adminKey{}) == nil {
+					return errors.New("not an admin")
+				}
+				return nil
+			},
+			func(ctx context.Context, kind string) {
+				bypassed = append(bypassed, kind)
+			},
+		))
+		assert.NoError(t, err)
+
+		query := func(ctx context.Context, db bunquery.QueryDB) error {
+			return db.NewRaw("SELECT 1").Scan(ctx, new(int))
+		}
+		err = bunquery.UseQuery(ctx, query, bunquery.WithRawPolicy(bunquery.AllowRaw))
+		assert.ErrorContains(t, err, "not an admin")
+		assert.Empty(t, bypassed)
+
+		ctx = context.WithValue(ctx, adminKey{}, true)
+		err = bunquery.UseQuery(ctx, query, bunquery.WithRawPolicy(bunquery.AllowRaw))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"security"}, bypassed)
+	})
+
+	t.Run("stricter policy", func(t *testing.T) {
+		err := bunquery.UseQuery(ctx, func(ctx context.Context, db bunquery.QueryDB) error {
+			return db.NewRaw("SELECT 1").Scan(ctx, new(int))
+		}, bunquery.WithRawPolicy(bunquery.DenyRawWith("security")))
+		assert.ErrorIs(t, err, bunquery.ErrRawDenied)
+	})
+}
+
+type adminKey struct{}
+
+// unrestrictiveHook is a mutation hook that allows raw statements.
+type unrestrictiveHook struct {
+	*bunquery.AuditMod
+}
+
+func (m unrestrictiveHook) Restrictive() bool { return false }
+
+type mergeDialect struct {
+	schema.Dialect
+}
+
+func (d mergeDialect) Features() feature.Feature { return d.Dialect.Features() | feature.Merge }
+
+func execErr(_ sql.Result, err error) error {
+	return err
+}
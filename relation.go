@@ -0,0 +1,171 @@
+package bunquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var errRelationUnsupported = errors.New("not supported on a relation")
+
+// Relation joins a relation with the mods of db applied to the related table, e.g.
+// db.NewSelect().Model(&stories).Apply(bunquery.Relation(db, "Author")). The query
+// fails when db does not apply mods to relations.
+func Relation(db QueryDB, name string, apply ...func(*bun.SelectQuery) *bun.SelectQuery) func(*bun.SelectQuery) *bun.SelectQuery {
+	if rdb, ok := db.(interface {
+		Relation(name string, apply ...func(*bun.SelectQuery) *bun.SelectQuery) func(*bun.SelectQuery) *bun.SelectQuery
+	}); ok {
+		return rdb.Relation(name, apply...)
+	}
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Err(fmt.Errorf("relation %q: %T does not apply mods to relations", name, db))
+	}
+}
+
+// applyRelationMods binds mods to the related table of a relation and adds their
+// conditions to the JOIN ON clause, or to the WHERE clause of the follow-up query
+// bun issues for has-many relations.
+func applyRelationMods(ctx context.Context, db bun.IDB, mods QueryMods, name string, apply ...func(*bun.SelectQuery) *bun.SelectQuery) func(*bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		table := getQueryTable(q)
+		if table == nil {
+			return q.Err(fmt.Errorf("relation %q requires a model", name))
+		}
+
+		joinTable, alias, err := resolveRelation(table, name)
+		if err != nil {
+			return q.Err(err)
+		}
+
+		rqb := &relationQueryBuilder{table: joinTable, alias: alias}
+		mods.Bind(ctx, db, rqb)
+		if rqb.err != nil {
+			return q.Err(rqb.err)
+		}
+
+		opts := bun.RelationOpts{}
+		if len(apply) > 0 {
+			opts.Apply = func(q *bun.SelectQuery) *bun.SelectQuery {
+				for _, fn := range apply {
+					q = fn(q)
+				}
+				return q
+			}
+		}
+		if len(rqb.where) > 0 {
+			opts.AdditionalJoinOnConditions = []schema.QueryWithArgs{
+				schema.SafeQuery("?", []any{rqb}),
+			}
+		}
+		return q.RelationWithOpts(name, opts)
+	}
+}
+
+func resolveRelation(table *schema.Table, name string) (*schema.Table, string, error) {
+	var path []string
+	var rel *schema.Relation
+	for part := range strings.SplitSeq(name, ".") {
+		if rel = table.Relations[part]; rel == nil {
+			return nil, "", fmt.Errorf("%s does not have relation=%q", table, part)
+		}
+		path = append(path, rel.Field.Name)
+		table = rel.JoinTable
+	}
+	switch rel.Type {
+	case schema.HasManyRelation, schema.ManyToManyRelation:
+		// Loaded by a separate query that uses the table's own alias.
+		return table, table.Alias, nil
+	default:
+		return table, strings.Join(path, "__"), nil
+	}
+}
+
+// relationQueryBuilder collects the conditions mods add for a related table. The
+// conditions are rendered with ?TableAlias bound to the relation alias.
+type relationQueryBuilder struct {
+	table *schema.Table
+	alias string
+	where []schema.QueryWithSep
+	err   error
+}
+
+var _ QueryBuilderEx = (*relationQueryBuilder)(nil)
+
+func (rqb *relationQueryBuilder) AppendQuery(fmter schema.QueryGen, b []byte) ([]byte, error) {
+	fmter = fmter.WithNamedArg("TableAlias", bun.Ident(rqb.alias))
+	for i, where := range rqb.where {
+		if i > 0 {
+			b = append(b, where.Sep...)
+		}
+		b = append(b, '(')
+		var err error
+		if b, err = where.AppendQuery(fmter, b); err != nil {
+			return nil, err
+		}
+		b = append(b, ')')
+	}
+	return b, nil
+}
+
+func (rqb *relationQueryBuilder) Operation() string    { return "SELECT" }
+func (rqb *relationQueryBuilder) GetModel() bun.Model  { return nil }
+func (rqb *relationQueryBuilder) GetTableName() string { return rqb.table.Name }
+func (rqb *relationQueryBuilder) Unwrap() any          { return rqb }
+func (rqb *relationQueryBuilder) Kind() QueryKind      { return QuerySelect }
+func (rqb *relationQueryBuilder) Table() *schema.Table { return rqb.table }
+
+func (rqb *relationQueryBuilder) WhereDeleted() bun.QueryBuilder {
+	return rqb.Err(fmt.Errorf("WhereDeleted: %w", errRelationUnsupported))
+}
+
+func (rqb *relationQueryBuilder) WhereAllWithDeleted() bun.QueryBuilder {
+	return rqb.Err(fmt.Errorf("WhereAllWithDeleted: %w", errRelationUnsupported))
+}
+
+func (rqb *relationQueryBuilder) WherePK(cols ...string) bun.QueryBuilder {
+	return rqb.Err(fmt.Errorf("WherePK: %w", errRelationUnsupported))
+}
+
+func (rqb *relationQueryBuilder) With(name string, query bun.Query) QueryBuilderEx {
+	return rqb.Err(fmt.Errorf("With: %w", errRelationUnsupported))
+}
+
+func (rqb *relationQueryBuilder) Err(err error) QueryBuilderEx {
+	if rqb.err == nil {
+		rqb.err = err
+	}
+	return rqb
+}
+
+func (rqb *relationQueryBuilder) Where(query string, args ...any) bun.QueryBuilder {
+	rqb.where = append(rqb.where, schema.SafeQueryWithSep(query, args, " AND "))
+	return rqb
+}
+
+func (rqb *relationQueryBuilder) WhereOr(query string, args ...any) bun.QueryBuilder {
+	rqb.where = append(rqb.where, schema.SafeQueryWithSep(query, args, " OR "))
+	return rqb
+}
+
+func (rqb *relationQueryBuilder) WhereGroup(sep string, fn func(bun.QueryBuilder) bun.QueryBuilder) bun.QueryBuilder {
+	group := &relationQueryBuilder{table: rqb.table, alias: rqb.alias}
+	fn(group)
+	if group.err != nil {
+		return rqb.Err(group.err)
+	}
+	if len(group.where) > 0 {
+		rqb.where = append(rqb.where, schema.SafeQueryWithSep("?", []any{group}, sep))
+	}
+	return rqb
+}
+
+func (rqb *relationQueryBuilder) WhereTable(fn func(table *schema.Table) (string, []any)) QueryBuilderEx {
+	if query, args := fn(rqb.table); query != "" {
+		rqb.Where(query, args...)
+	}
+	return rqb
+}
@@ -0,0 +1,122 @@
+package bunquery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/mmorton/bunquery"
+)
+
+type relationUser struct {
+	bun.BaseModel `bun:"table:relation_users"`
+	ID            int64 `bun:",pk"`
+	Name          string
+	Hidden        bool
+	Stories       []*relationStory `bun:"rel:has-many,join:id=author_id"`
+}
+
+type relationStory struct {
+	bun.BaseModel `bun:"table:relation_stories"`
+	ID            int64 `bun:",pk"`
+	Title         string
+	AuthorID      int64
+	Hidden        bool
+	Author        *relationUser `bun:"rel:belongs-to,join:author_id=id"`
+}
+
+// hiddenMod hides the rows of every table with a hidden column.
+var hiddenMod = bunquery.NewQueryMod("hidden", func(ctx context.Context, iDB bun.IDB, query bunquery.QueryBuilderEx, args ...any) {
+	query.WhereTable(func(table *schema.Table) (string, []any) {
+		if _, ok := table.FieldMap["hidden"]; ok {
+			return "?TableAlias.hidden = FALSE", nil
+		}
+		return "", nil
+	})
+})
+
+func TestRelation(t *testing.T) {
+	db := newSQLiteDB(t, (*relationUser)(nil), (*relationStory)(nil))
+	ctx := context.Background()
+	_, err := db.NewInsert().Model(&[]relationUser{
+		{ID: 1, Name: "visible"},
+		{ID: 2, Name: "hidden", Hidden: true},
+	}).Exec(ctx)
+	assert.NoError(t, err)
+	_, err = db.NewInsert().Model(&[]relationStory{
+		{ID: 1, Title: "a", AuthorID: 1},
+		{ID: 2, Title: "b", AuthorID: 2},
+		{ID: 3, Title: "c", AuthorID: 1, Hidden: true},
+		{ID: 4, Title: "d", AuthorID: 1},
+	}).Exec(ctx)
+	assert.NoError(t, err)
+	ctx = bunquery.NewContext(ctx, db, hiddenMod)
+
+	qDB, err := bunquery.UseQueryDB(ctx)
+	assert.NoError(t, err)
+
+	t.Run("belongs to", func(t *testing.T) {
+		var stories []relationStory
+		err := qDB.NewSelect().Model(&stories).Apply(bunquery.Relation(qDB, "Author")).Order("relation_story.id").Scan(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, stories, 3) {
+			assert.Equal(t, "visible", stories[0].Author.Name)
+			assert.Nil(t, stories[1].Author)
+			assert.Equal(t, "visible", stories[2].Author.Name)
+		}
+	})
+
+	t.Run("has many", func(t *testing.T) {
+		var users []relationUser
+		err := bun.NewSelectQuery(db).Model(&users).Apply(bunquery.Relation(qDB, "Stories")).Order("relation_user.id").Scan(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, users, 2) {
+			assert.Len(t, users[0].Stories, 2)
+			assert.Len(t, users[1].Stories, 1)
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		var users []relationUser
+		err := qDB.NewSelect().Model(&users).Apply(bunquery.Relation(qDB, "Stories",
+			func(q *bun.SelectQuery) *bun.SelectQuery { return q.Where("title != 'a'") },
+			func(q *bun.SelectQuery) *bun.SelectQuery { return q.Column("id", "author_id") },
+		)).Scan(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, users, 1) && assert.Len(t, users[0].Stories, 1) {
+			assert.Equal(t, int64(4), users[0].Stories[0].ID)
+			assert.Empty(t, users[0].Stories[0].Title)
+		}
+	})
+
+	t.Run("table", func(t *testing.T) {
+		var tables []string
+		ctx := bunquery.NewContext(context.Background(), db, bunquery.NewQueryMod("table", func(ctx context.Context, iDB bun.IDB, query bunquery.QueryBuilderEx, args ...any) {
+			if table := query.Table(); table != nil {
+				tables = append(tables, table.TypeName)
+			} else {
+				tables = append(tables, "")
+			}
+		}))
+		qDB, err := bunquery.UseQueryDB(ctx)
+		assert.NoError(t, err)
+		var stories []relationStory
+		assert.NoError(t, qDB.NewSelect().Model(&stories).Apply(bunquery.Relation(qDB, "Author")).Scan(ctx))
+		// The query model is set after the mods are bound, the relation model before.
+		assert.Equal(t, []string{"", "RelationUser"}, tables)
+	})
+
+	t.Run("unsupported db", func(t *testing.T) {
+		var stories []relationStory
+		err := qDB.NewSelect().Model(&stories).Apply(bunquery.Relation(plainQueryDB{qDB}, "Author")).Scan(ctx)
+		assert.ErrorContains(t, err, "does not apply mods to relations")
+	})
+}
+
+// plainQueryDB is a QueryDB that does not forward Relation.
+type plainQueryDB struct {
+	bunquery.QueryDB
+}
@@ -0,0 +1,199 @@
+package bunquery
+
+import (
+	"strings"
+
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+type SearchOptions struct {
+	Config   string
+	FTSTable string
+	Fallback bool
+}
+
+type SearchOption = func(*SearchOptions)
+
+// WithSearchConfig sets the postgres text search configuration, "simple" by default.
+func WithSearchConfig(config string) SearchOption {
+	return func(opts *SearchOptions) {
+		opts.Config = config
+	}
+}
+
+// WithFTSTable names the SQLite FTS5 table that indexes the searched columns. Its rowid
+// must match the rowid of the searched table.
+func WithFTSTable(table string) SearchOption {
+	return func(opts *SearchOptions) {
+		opts.FTSTable = table
+	}
+}
+
+// WithSearchFallback always searches with LIKE, or ILIKE on postgres.
+func WithSearchFallback() SearchOption {
+	return func(opts *SearchOptions) {
+		opts.Fallback = true
+	}
+}
+
+// TextSearch searches a set of text columns with the best method the dialect offers:
+// full text search on postgres, FTS5 on SQLite when a table is configured and LIKE
+// over the columns otherwise. Columns are column names, Column references or other
+// appenders.
+type TextSearch struct {
+	cols []any
+	opts *SearchOptions
+}
+
+func NewTextSearch(cols []any, opts ...SearchOption) *TextSearch {
+	res := &SearchOptions{Config: "simple"}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return &TextSearch{cols: cols, opts: res}
+}
+
+type searchMethod int
+
+const (
+	searchLike searchMethod = iota
+	searchTSVector
+	searchFTS5
+)
+
+func (s *TextSearch) method(name dialect.Name) searchMethod {
+	switch {
+	case s.opts.Fallback:
+		return searchLike
+	case name == dialect.PG:
+		return searchTSVector
+	case name == dialect.SQLite && s.opts.FTSTable != "":
+		return searchFTS5
+	default:
+		return searchLike
+	}
+}
+
+// Match appends the search condition. Blank text matches every row.
+func (s *TextSearch) Match(text string) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		terms := strings.Fields(text)
+		if len(terms) == 0 {
+			return append(b, "1 = 1"...), nil
+		}
+		switch s.method(fmter.Dialect().Name()) {
+		case searchTSVector:
+			b, err := s.appendTSVector(fmter, b)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, " @@ "...)
+			return s.appendTSQuery(fmter, b, text), nil
+		case searchFTS5:
+			return fmter.AppendQuery(b, "?TableAlias.rowid IN (SELECT rowid FROM ? WHERE ? MATCH ?)",
+				schema.Ident(s.opts.FTSTable), schema.Ident(s.opts.FTSTable), getFTS5Query(terms)), nil
+		default:
+			return s.appendLike(fmter, b, terms, " AND ", " OR ", "")
+		}
+	}
+}
+
+// Rank appends an expression that is larger for better matches, for use as a sort key.
+func (s *TextSearch) Rank(text string) QueryAppenderFunc {
+	return func(fmter schema.QueryGen, b []byte) ([]byte, error) {
+		terms := strings.Fields(text)
+		if len(terms) == 0 {
+			return append(b, '0'), nil
+		}
+		switch s.method(fmter.Dialect().Name()) {
+		case searchTSVector:
+			b = append(b, "ts_rank("...)
+			b, err := s.appendTSVector(fmter, b)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, ", "...)
+			b = s.appendTSQuery(fmter, b, text)
+			return append(b, ')'), nil
+		case searchFTS5:
+			// FTS5 ranks better matches lower.
+			return fmter.AppendQuery(b, "coalesce((SELECT -rank FROM ? WHERE ? MATCH ? AND rowid = ?TableAlias.rowid), 0)",
+				schema.Ident(s.opts.FTSTable), schema.Ident(s.opts.FTSTable), getFTS5Query(terms)), nil
+		default:
+			// The number of matching terms and columns.
+			b = append(b, '(')
+			b, err := s.appendLike(fmter, b, terms, " + ", " + ", " THEN 1 ELSE 0 END")
+			if err != nil {
+				return nil, err
+			}
+			return append(b, ')'), nil
+		}
+	}
+}
+
+func (s *TextSearch) appendTSVector(fmter schema.QueryGen, b []byte) ([]byte, error) {
+	b = fmter.AppendQuery(b, "to_tsvector(?::regconfig, concat_ws(' '", s.opts.Config)
+	for _, col := range s.cols {
+		b = append(b, ", "...)
+		var err error
+		if b, err = appendColumn(fmter, b, col); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, "))"...), nil
+}
+
+func (s *TextSearch) appendTSQuery(fmter schema.QueryGen, b []byte, text string) []byte {
+	return fmter.AppendQuery(b, "plainto_tsquery(?::regconfig, ?)", s.opts.Config, text)
+}
+
+// appendLike appends a LIKE test per term and column, joining the columns of a term
+// with colSep and the terms with termSep. A non-empty then turns every test into a
+// CASE expression.
+func (s *TextSearch) appendLike(fmter schema.QueryGen, b []byte, terms []string, termSep, colSep, then string) ([]byte, error) {
+	op := " LIKE "
+	if fmter.Dialect().Name() == dialect.PG {
+		op = " ILIKE "
+	}
+	for i, term := range terms {
+		if i > 0 {
+			b = append(b, termSep...)
+		}
+		b = append(b, '(')
+		for j, col := range s.cols {
+			if j > 0 {
+				b = append(b, colSep...)
+			}
+			if then != "" {
+				b = append(b, "CASE WHEN "...)
+			}
+			var err error
+			if b, err = appendColumn(fmter, b, col); err != nil {
+				return nil, err
+			}
+			b = append(b, op...)
+			b = fmter.AppendQuery(b, "? ESCAPE '!'", "%"+escapeLike(term)+"%")
+			b = append(b, then...)
+		}
+		b = append(b, ')')
+	}
+	return b, nil
+}
+
+// likeEscaper escapes with "!", as a backslash is itself an escape in MySQL strings.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// getFTS5Query quotes every term, so the text is not parsed as FTS5 query syntax and
+// all terms have to match.
+func getFTS5Query(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,33 @@
+package bunquery_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/mmorton/bunquery"
+)
+
+func TestTextSearch(t *testing.T) {
+	cols := []any{"title", "body"}
+	var tests = []struct {
+		dialect dialect.Name
+		expr    schema.QueryAppender
+		want    string
+	}{
+		{dialect.PG, bunquery.NewTextSearch(cols).Match("go"), `to_tsvector('simple'::regconfig, concat_ws(' ', "title", "body")) @@ plainto_tsquery('simple'::regconfig, 'go')`},
+		{dialect.PG, bunquery.NewTextSearch(cols, bunquery.WithSearchFallback()).Match("5%"), `("title" ILIKE '%5!%%' ESCAPE '!' OR "body" ILIKE '%5!%%' ESCAPE '!')`},
+		{dialect.SQLite, bunquery.NewTextSearch(cols, bunquery.WithFTSTable("fts")).Match(`a "b"`), `?TableAlias.rowid IN (SELECT rowid FROM "fts" WHERE "fts" MATCH '"a" """b"""')`},
+		{dialect.MySQL, bunquery.NewTextSearch(cols[:1]).Match("a b"), `("title" LIKE '%a%' ESCAPE '!') AND ("title" LIKE '%b%' ESCAPE '!')`},
+		{dialect.MySQL, bunquery.NewTextSearch(cols).Rank("a"), `((CASE WHEN "title" LIKE '%a%' ESCAPE '!' THEN 1 ELSE 0 END + CASE WHEN "body" LIKE '%a%' ESCAPE '!' THEN 1 ELSE 0 END))`},
+		{dialect.MySQL, bunquery.NewTextSearch(cols).Match(" "), `1 = 1`},
+	}
+
+	for _, tt := range tests {
+		b, err := tt.expr.AppendQuery(newQueryGen(tt.dialect), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, string(b))
+	}
+}
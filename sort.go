@@ -1,6 +1,7 @@
 package bunquery
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"hash/crc32"
@@ -10,8 +11,11 @@ import (
 	"sync"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
 )
 
+// Sortable overrides how the pager reads the sort values of a model. Models that don't
+// implement it have their values read from the fields of the registered sort columns.
 type Sortable interface {
 	GetSortValues(rid uint32) []any
 }
@@ -27,16 +31,18 @@ type PagingRequest interface {
 	GetOrder() string
 }
 
-type Sort[M Sortable] struct {
+type Sort[M any] struct {
 	id    uint32
 	model M
-	cols  []string // registered columns
-	dirs  []uint8  // registered directions
-	vals  int      // num values required
-	def   bool     // is this the default sort
+	cols  []string                        // registered columns
+	exprs map[string]schema.QueryAppender // registered column expressions
+	dirs  []uint8                         // registered directions
+	vals  int                             // num values required
+	def   bool                            // is this the default sort
+	paths [][]*schema.Field               // resolved fields of the columns
 }
 
-func NewSort[M Sortable](model M) *Sort[M] {
+func NewSort[M any](model M) *Sort[M] {
 	return &Sort[M]{model: model}
 }
 
@@ -47,6 +53,18 @@ func (s *Sort[M]) Column(cols ...string) *Sort[M] {
 	return s
 }
 
+// ColumnExpr adds a column that sorts by an expression, such as a JSONPath. The name
+// stands for the expression in orders and continuation tokens.
+func (s *Sort[M]) ColumnExpr(name string, expr schema.QueryAppender) *Sort[M] {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if s.exprs == nil {
+		s.exprs = map[string]schema.QueryAppender{}
+	}
+	s.exprs[name] = expr
+	s.cols = append(s.cols, name)
+	return s
+}
+
 func (s *Sort[M]) Direction(dirs ...uint8) *Sort[M] {
 	s.dirs = append(s.dirs, dirs...)
 	return s
@@ -86,9 +104,21 @@ func (s *Sort[M]) checkAndRegister() (uint32, error) {
 	regsM.Lock()
 	defer regsM.Unlock()
 
+	if len(s.dirs) != len(s.cols) {
+		return 0, fmt.Errorf("sort has %d columns but %d directions", len(s.cols), len(s.dirs))
+	}
+
 	s.id = sid(s.model, s.cols)
 	s.vals = len(s.cols)
 
+	if _, ok := any(s.model).(Sortable); !ok {
+		paths, err := resolveSortPaths(s.model, s.cols)
+		if err != nil {
+			return 0, err
+		}
+		s.paths = paths
+	}
+
 	regs[s.id] = s
 
 	if s.def {
@@ -123,7 +153,7 @@ func (s *Sort[M]) getResolvedDirections(prev *Continuation) []uint8 {
 		return nil
 	}
 
-	res := make([]uint8, len(s.dirs))
+	res := make([]uint8, 0, len(s.dirs))
 	for i, dir := range s.dirs {
 		if i < len(prev.Directions) {
 			dir = prev.Directions[i]
@@ -141,7 +171,7 @@ func (s *Sort[M]) getResolvedDirections(prev *Continuation) []uint8 {
 	return res
 }
 
-func matchSID[M Sortable](rid uint32) (*Sort[M], error) {
+func matchSID[M any](rid uint32) (*Sort[M], error) {
 	regsM.RLock()
 	defer regsM.RUnlock()
 	if s, ok := regs[rid]; !ok {
@@ -153,7 +183,7 @@ func matchSID[M Sortable](rid uint32) (*Sort[M], error) {
 	}
 }
 
-func matchDefaults[M Sortable](model M) (*Sort[M], error) {
+func matchDefaults[M any](model M) (*Sort[M], error) {
 	regsM.RLock()
 	defer regsM.RUnlock()
 	m := getUnderlyingPointerType(model)
@@ -188,8 +218,9 @@ func parseOrder(order string) ([]string, []uint8) {
 }
 
 type PagerOptions struct {
-	ForwardOnly bool
-	PageSize    int
+	ForwardOnly  bool
+	PageSize     int
+	Continuation []ContinuationOption
 }
 
 type PagerOption = func(*PagerOptions)
@@ -206,7 +237,14 @@ func WithPageSize(size int) PagerOption {
 	}
 }
 
-type Pager[M Sortable] struct {
+// WithContinuationOptions applies the options to the tokens the pager parses and formats.
+func WithContinuationOptions(opts ...ContinuationOption) PagerOption {
+	return func(pagerOpts *PagerOptions) {
+		pagerOpts.Continuation = append(pagerOpts.Continuation, opts...)
+	}
+}
+
+type Pager[M any] struct {
 	from *Sort[M]
 	cont *Continuation
 	dirs []uint8
@@ -222,7 +260,7 @@ func NewOptions(opts ...PagerOption) *PagerOptions {
 	return res
 }
 
-func NewPager[M Sortable](model M, opts ...PagerOption) (*Pager[M], error) {
+func NewPager[M any](model M, opts ...PagerOption) (*Pager[M], error) {
 	if sort, err := matchDefaults(model); err != nil {
 		return nil, err
 	} else {
@@ -230,7 +268,7 @@ func NewPager[M Sortable](model M, opts ...PagerOption) (*Pager[M], error) {
 	}
 }
 
-func NewPagerFromSID[M Sortable](sid uint32, opts ...PagerOption) (*Pager[M], error) {
+func NewPagerFromSID[M any](sid uint32, opts ...PagerOption) (*Pager[M], error) {
 	if sort, err := matchSID[M](sid); err != nil {
 		return nil, err
 	} else {
@@ -238,7 +276,7 @@ func NewPagerFromSID[M Sortable](sid uint32, opts ...PagerOption) (*Pager[M], er
 	}
 }
 
-func NewPagerFromOrder[M Sortable](model M, order string, opts ...PagerOption) (*Pager[M], error) {
+func NewPagerFromOrder[M any](model M, order string, opts ...PagerOption) (*Pager[M], error) {
 	cols, dirs := parseOrder(order)
 	sid := sid(model, cols)
 	if sort, err := matchSID[M](sid); err != nil {
@@ -248,17 +286,24 @@ func NewPagerFromOrder[M Sortable](model M, order string, opts ...PagerOption) (
 	}
 }
 
-func NewPagerFromContinuation[M Sortable](token string, opts ...PagerOption) (*Pager[M], error) {
-	if cont, err := ParseContinuation(token); err != nil {
+// NewPagerFromContinuation parses the token with the continuation options of the pager,
+// so it fails with ErrContinuationSignature or ErrContinuationKey as ParseContinuation does.
+func NewPagerFromContinuation[M any](token string, opts ...PagerOption) (*Pager[M], error) {
+	options := NewOptions(opts...)
+	if cont, err := ParseContinuation(token, options.Continuation...); err != nil {
 		return nil, err
 	} else if sort, err := matchSID[M](cont.SID); err != nil {
 		return nil, err
+	} else if len(cont.Directions) != len(sort.cols) {
+		return nil, fmt.Errorf("continuation has %d directions for %d sort columns", len(cont.Directions), len(sort.cols))
+	} else if len(cont.Values) != len(sort.cols) {
+		return nil, fmt.Errorf("continuation has %d values for %d sort columns", len(cont.Values), len(sort.cols))
 	} else {
-		return &Pager[M]{from: sort, dirs: sort.getResolvedDirections(cont), rvrs: cont.Reverse, cont: cont, opts: NewOptions(opts...)}, nil
+		return &Pager[M]{from: sort, dirs: sort.getResolvedDirections(cont), rvrs: cont.Reverse, cont: cont, opts: options}, nil
 	}
 }
 
-func NewPagerFromRequest[M Sortable](model M, req PagingRequest, opts ...PagerOption) (*Pager[M], error) {
+func NewPagerFromRequest[M any](model M, req PagingRequest, opts ...PagerOption) (*Pager[M], error) {
 	if req.GetContinue() != "" {
 		return NewPagerFromContinuation[M](req.GetContinue(), opts...)
 	} else if req.GetOrder() != "" {
@@ -276,11 +321,13 @@ func (p *Pager[M]) Compile() func(qry *bun.SelectQuery) *bun.SelectQuery {
 				for i := range p.from.cols {
 					q = q.WhereGroup(" OR ", func(q *bun.SelectQuery) *bun.SelectQuery {
 						for j := range i + 1 {
+							op := getSortCompOp(p.dirs[j], i, j, len(p.from.cols), p.cont.Include)
+							if expr, ok := p.from.exprs[p.from.cols[j]]; ok {
+								q = q.Where(fmt.Sprintf("? %s ?", op), expr, p.cont.Values[j])
+								continue
+							}
 							q = q.Where(
-								fmt.Sprintf("%s? %s ?",
-									getSortTableAlias(p.from.cols[j]),
-									getSortCompOp(p.dirs[j], i, j, len(p.from.cols), p.cont.Include),
-								),
+								fmt.Sprintf("%s? %s ?", getSortTableAlias(p.from.cols[j]), op),
 								bun.Ident(p.from.cols[j]),
 								p.cont.Values[j],
 							)
@@ -292,7 +339,6 @@ func (p *Pager[M]) Compile() func(qry *bun.SelectQuery) *bun.SelectQuery {
 			})
 		}
 
-		var order []string
 		for i, col := range p.from.cols {
 			dir := p.from.dirs[i]
 			if i < len(p.dirs) {
@@ -302,9 +348,12 @@ func (p *Pager[M]) Compile() func(qry *bun.SelectQuery) *bun.SelectQuery {
 			if dir == SortDescending {
 				sort = "DESC"
 			}
-			order = append(order, fmt.Sprintf("%s %s", col, sort))
+			if expr, ok := p.from.exprs[col]; ok {
+				qry = qry.OrderExpr("? "+sort, expr)
+			} else {
+				qry = qry.Order(fmt.Sprintf("%s %s", col, sort))
+			}
 		}
-		qry = qry.Order(order...)
 
 		if p.opts.PageSize > 0 {
 			qry = qry.Limit(p.opts.PageSize)
@@ -320,7 +369,7 @@ func (p *Pager[M]) getFirstLastSortValues(results []M) ([]any, []any) {
 	}
 	f := results[0]
 	l := results[len(results)-1]
-	fv, lv := f.GetSortValues(p.from.id), l.GetSortValues(p.from.id)
+	fv, lv := p.from.getSortValues(f), p.from.getSortValues(l)
 	if p.rvrs {
 		return lv, fv
 	} else {
@@ -328,16 +377,36 @@ func (p *Pager[M]) getFirstLastSortValues(results []M) ([]any, []any) {
 	}
 }
 
+// ErrNullSortValue fails Map when a row a continuation starts from has a NULL sort value,
+// such as a column of a relation that is not loaded, as NULL can't be compared.
+var ErrNullSortValue = errors.New("sort value is NULL")
+
+func (p *Pager[M]) formatContinuation(vals []any, reverse bool) (string, error) {
+	for i, val := range vals {
+		if val == nil {
+			return "", fmt.Errorf("%w: %s", ErrNullSortValue, p.from.cols[i])
+		}
+	}
+	return FormatContinuation(p.from.id, p.dirs, vals, reverse, false, p.opts.Continuation...)
+}
+
 func (p *Pager[M]) Map(results []M) ([]M, string, string, error) {
 	if len(results) > 0 {
 		fv, lv := p.getFirstLastSortValues(results)
-		next, err := FormatContinuation(p.from.id, p.dirs, lv, false, false)
-		if err != nil {
-			return results, "", "", err
+
+		// Tokens that don't make sense are left empty.
+		short := p.opts.PageSize > 0 && len(results) < p.opts.PageSize
+		var next, prev string
+		var err error
+		if !short || p.rvrs {
+			if next, err = p.formatContinuation(lv, false); err != nil {
+				return results, "", "", err
+			}
 		}
-		prev, err := FormatContinuation(p.from.id, p.dirs, fv, true, false)
-		if err != nil {
-			return results, "", "", err
+		if !short || !p.rvrs {
+			if prev, err = p.formatContinuation(fv, true); err != nil {
+				return results, "", "", err
+			}
 		}
 
 		if p.rvrs {
@@ -348,28 +417,17 @@ func (p *Pager[M]) Map(results []M) ([]M, string, string, error) {
 			results = temp
 		}
 
-		// Zero out tokens that don't make sense.
-		if p.rvrs {
-			if p.opts.PageSize > 0 && len(results) < p.opts.PageSize {
-				prev = ""
-			}
-		} else {
-			if p.opts.PageSize > 0 && len(results) < p.opts.PageSize {
-				next = ""
-			}
-		}
-
 		return results, next, prev, nil
 	} else if p.cont != nil {
 		// This creates a reflection of the last page (which includes the last result value)
 		if p.cont.Reverse {
-			if nt, err := FormatContinuation(p.cont.SID, p.cont.Directions, p.cont.Values, false, true); err != nil {
+			if nt, err := FormatContinuation(p.cont.SID, p.cont.Directions, p.cont.Values, false, true, p.opts.Continuation...); err != nil {
 				return results, "", "", err
 			} else {
 				return results, nt, "", nil
 			}
 		} else {
-			if pt, err := FormatContinuation(p.cont.SID, p.cont.Directions, p.cont.Values, true, true); err != nil {
+			if pt, err := FormatContinuation(p.cont.SID, p.cont.Directions, p.cont.Values, true, true, p.opts.Continuation...); err != nil {
 				return results, "", "", err
 			} else {
 				return results, "", pt, nil
@@ -442,6 +500,83 @@ func getSortTableAlias(fld string) string {
 	}
 }
 
+// resolveSortPaths maps the sort columns to the fields that hold their values. Columns
+// qualified by a relation, as in "author.name" or "author__company.name", are read
+// through the relation fields.
+func resolveSortPaths(model any, cols []string) ([][]*schema.Field, error) {
+	typ := reflect.TypeOf(getUnderlyingPointerType(model))
+	base := modelTables.Get(typ)
+
+	res := make([][]*schema.Field, 0, len(cols))
+	for _, col := range cols {
+		table := base
+		var path []*schema.Field
+		prefix, name, ok := strings.Cut(strings.ToLower(col), ".")
+		if !ok {
+			prefix, name = "", prefix
+		}
+		if prefix != "" && prefix != base.Alias && prefix != base.Name {
+			for rname := range strings.SplitSeq(prefix, "__") {
+				var rel *schema.Relation
+				for _, r := range table.Relations {
+					if r.Field.Name == rname {
+						rel = r
+					}
+				}
+				if rel == nil {
+					return nil, fmt.Errorf("sort column %q does not map to a relation of %s", col, table.TypeName)
+				} else if rel.Type != schema.HasOneRelation && rel.Type != schema.BelongsToRelation {
+					return nil, fmt.Errorf("sort column %q is not on a has-one or belongs-to relation of %s", col, table.TypeName)
+				}
+				path = append(path, rel.Field)
+				table = rel.JoinTable
+			}
+		}
+
+		field, ok := table.FieldMap[name]
+		if !ok {
+			return nil, fmt.Errorf("sort column %q does not map to a field of %s, implement Sortable to provide its values", col, table.TypeName)
+		}
+		res = append(res, append(path, field))
+	}
+	return res, nil
+}
+
+func (s *Sort[M]) getSortValues(model M) []any {
+	if m, ok := any(model).(Sortable); ok {
+		return m.GetSortValues(s.id)
+	}
+
+	strct := reflect.Indirect(reflect.ValueOf(model))
+	res := make([]any, len(s.paths))
+	for i, path := range s.paths {
+		res[i] = getSortValue(strct, path)
+	}
+	return res
+}
+
+// getSortValue reads the field at the end of path, which is nil when a relation on the
+// way is not loaded. Map refuses to continue from nil values.
+func getSortValue(strct reflect.Value, path []*schema.Field) any {
+	for _, rel := range path[:len(path)-1] {
+		strct = reflect.Indirect(rel.Value(strct))
+		if !strct.IsValid() {
+			return nil
+		}
+	}
+
+	value := path[len(path)-1].Value(strct)
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil
+	}
+	if valuer, ok := value.Interface().(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			return v
+		}
+	}
+	return value.Interface()
+}
+
 func getUnderlyingPointerType(model any) any {
 	t := reflect.TypeOf(model)
 	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
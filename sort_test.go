@@ -0,0 +1,106 @@
+package bunquery_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmorton/bunquery"
+)
+
+type sortAuthor struct {
+	ID   int64 `bun:",pk"`
+	Name string
+}
+
+type sortBook struct {
+	ID       int64 `bun:",pk"`
+	Title    sql.NullString
+	AuthorID int64
+	Author   *sortAuthor `bun:"rel:belongs-to,join:author_id=id"`
+}
+
+type sortOverride struct {
+	ID int64 `bun:",pk"`
+}
+
+func (m *sortOverride) GetSortValues(rid uint32) []any {
+	return []any{-m.ID}
+}
+
+func TestSortValues(t *testing.T) {
+	bunquery.NewSort((*sortBook)(nil)).Column("author.name", "title", "sort_book.id").Direction(0, 0, 0).MustRegister()
+
+	pager, err := bunquery.NewPager((*sortBook)(nil))
+	assert.NoError(t, err)
+	_, next, _, err := pager.Map([]*sortBook{
+		{ID: 1, Author: &sortAuthor{Name: "a"}, Title: sql.NullString{String: "s", Valid: true}},
+		{ID: 2, Author: &sortAuthor{Name: "b"}, Title: sql.NullString{String: "t", Valid: true}},
+	})
+	assert.NoError(t, err)
+	cont, err := bunquery.ParseContinuation(next)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"b", "t", int64(2)}, cont.Values)
+
+	// NULL values, from relations that are not loaded or NULL columns, can't be continued from.
+	_, _, _, err = pager.Map([]*sortBook{{ID: 1, Title: sql.NullString{String: "t", Valid: true}}})
+	assert.ErrorIs(t, err, bunquery.ErrNullSortValue)
+	assert.ErrorContains(t, err, "author.name")
+	_, _, _, err = pager.Map([]*sortBook{{ID: 1, Author: &sortAuthor{Name: "a"}}})
+	assert.ErrorIs(t, err, bunquery.ErrNullSortValue)
+
+	bunquery.NewSort((*sortOverride)(nil)).Column("id").Direction(0).MustRegister()
+	override, err := bunquery.NewPager((*sortOverride)(nil))
+	assert.NoError(t, err)
+	_, next, _, err = override.Map([]*sortOverride{{ID: 3}})
+	assert.NoError(t, err)
+	cont, err = bunquery.ParseContinuation(next)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(-3)}, cont.Values)
+
+	_, err = bunquery.NewSort((*sortBook)(nil)).Column("missing").Direction(0).Register()
+	assert.ErrorContains(t, err, `sort column "missing" does not map to a field of SortBook`)
+}
+
+func TestSortDirections(t *testing.T) {
+	_, err := bunquery.NewSort((*sortBook)(nil)).Column("title", "sort_book.id").Direction(0).Register()
+	assert.ErrorContains(t, err, "sort has 2 columns but 1 directions")
+
+	// Tokens must hold a value for every column of their sort.
+	bunquery.NewSort((*sortDirections)(nil)).Column("name", "id").Direction(0, 1).MustRegister()
+	pager, err := bunquery.NewPager((*sortDirections)(nil))
+	assert.NoError(t, err)
+	_, next, _, err := pager.Map([]*sortDirections{{ID: 1, Name: "a"}})
+	assert.NoError(t, err)
+	cont, err := bunquery.ParseContinuation(next)
+	assert.NoError(t, err)
+	_, err = bunquery.NewPagerFromContinuation[*sortDirections](next)
+	assert.NoError(t, err)
+
+	short, err := bunquery.FormatContinuation(cont.SID, cont.Directions, cont.Values[:1], false, false)
+	assert.NoError(t, err)
+	_, err = bunquery.NewPagerFromContinuation[*sortDirections](short)
+	assert.ErrorContains(t, err, "for 2 sort columns")
+}
+
+type sortDirections struct {
+	ID   int64 `bun:",pk"`
+	Name string
+}
+
+func TestSignedPager(t *testing.T) {
+	bunquery.NewSort((*sortBook)(nil)).Column("author.name", "title", "sort_book.id").Direction(0, 0, 0).MustRegister()
+
+	signed := bunquery.WithContinuationOptions(bunquery.WithSigningKeys([]byte("key")))
+	pager, err := bunquery.NewPager((*sortBook)(nil), signed)
+	assert.NoError(t, err)
+	_, next, _, err := pager.Map([]*sortBook{{ID: 1, Author: &sortAuthor{Name: "a"}, Title: sql.NullString{String: "t", Valid: true}}})
+	assert.NoError(t, err)
+
+	_, err = bunquery.NewPagerFromContinuation[*sortBook](next, signed)
+	assert.NoError(t, err)
+	_, err = bunquery.NewPagerFromContinuation[*sortBook](next, bunquery.WithContinuationOptions(bunquery.WithSigningKeys([]byte("other"))))
+	assert.True(t, errors.Is(err, bunquery.ErrContinuationSignature))
+}
@@ -0,0 +1,14 @@
+package bunquery
+
+import "github.com/uptrace/bun/schema"
+
+// modelTables resolves the models of column references, diffs and sorts, which are
+// built without a db. It is shared, so the table of each model is built once.
+var modelTables = schema.NewNopQueryGen().Dialect().Tables()
+
+// RegisterModel registers models with the tables used without a db, as db.RegisterModel
+// does for a db. The junction models of m2m relations must be registered before models
+// with those relations are used by Column, DiffPatch or NewSort.
+func RegisterModel(models ...any) {
+	modelTables.Register(models...)
+}
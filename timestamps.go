@@ -0,0 +1,143 @@
+package bunquery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+type clockCtxKey struct{}
+
+func NewClockContext(ctx context.Context, clock func() time.Time) context.Context {
+	return context.WithValue(ctx, clockCtxKey{}, clock)
+}
+
+func ClockFromContext(ctx context.Context) func() time.Time {
+	if clock, ok := ctx.Value(clockCtxKey{}).(func() time.Time); ok && clock != nil {
+		return clock
+	}
+	return time.Now
+}
+
+type TimestampOptions struct {
+	CreatedAt string
+	UpdatedAt string
+}
+
+type TimestampOption = func(*TimestampOptions)
+
+func WithCreatedAtColumn(col string) TimestampOption {
+	return func(opts *TimestampOptions) {
+		opts.CreatedAt = col
+	}
+}
+
+func WithUpdatedAtColumn(col string) TimestampOption {
+	return func(opts *TimestampOptions) {
+		opts.UpdatedAt = col
+	}
+}
+
+type TimestampMod struct {
+	opts *TimestampOptions
+}
+
+var _ QueryMod = (*TimestampMod)(nil)
+var _ MutationHook = (*TimestampMod)(nil)
+
+func NewTimestampMod(opts ...TimestampOption) *TimestampMod {
+	res := &TimestampOptions{CreatedAt: "created_at", UpdatedAt: "updated_at"}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return &TimestampMod{opts: res}
+}
+
+func (m *TimestampMod) Kind() string { return "timestamps" }
+
+func (m *TimestampMod) Bind(ctx context.Context, db bun.IDB, qry QueryBuilderEx, args ...any) {}
+
+func (m *TimestampMod) BeforeMutation(ctx context.Context, db bun.IDB, event *MutationEvent) (context.Context, error) {
+	if event.Table == nil {
+		return ctx, nil
+	}
+
+	now := ClockFromContext(ctx)()
+	created := event.Table.FieldMap[m.opts.CreatedAt]
+	updated := event.Table.FieldMap[m.opts.UpdatedAt]
+
+	switch event.Kind() {
+	case QueryInsert:
+		writes := getQueryWrites(event.Query, event.Table)
+		for _, field := range []*schema.Field{created, updated} {
+			if field == nil {
+				continue
+			}
+			if !writes.hasField(field.Name) {
+				// Column lists that leave out the timestamp get it added.
+				event.Query.(*bun.InsertQuery).Column(field.Name)
+			}
+			for _, strct := range event.Models() {
+				if !field.HasZeroValue(strct) {
+					continue
+				}
+				if err := setTimeValue(field.Value(strct), now); err != nil {
+					return ctx, fmt.Errorf("%s.%s: %w", event.Table.TypeName, field.GoName, err)
+				}
+			}
+		}
+	case QueryUpdate:
+		if updated == nil {
+			return ctx, nil
+		}
+		writes := getQueryWrites(event.Query, event.Table)
+		if slices.Contains(writes.set, updated.Name) {
+			// The statement sets the timestamp itself.
+			return ctx, nil
+		}
+		if !writes.hasField(updated.Name) {
+			// Column lists, SET clauses and updates by filter don't write the timestamp
+			// from the model, so it has to be added to them.
+			event.Query.(*bun.UpdateQuery).Set("? = ?", bun.Ident(updated.Name), now)
+			if event.Changes != nil {
+				event.Changes[updated.Name] = now
+			}
+		}
+		for _, strct := range event.Models() {
+			if err := setTimeValue(updated.Value(strct), now); err != nil {
+				return ctx, fmt.Errorf("%s.%s: %w", event.Table.TypeName, updated.GoName, err)
+			}
+		}
+	default:
+		return ctx, nil
+	}
+
+	event.Rebuild()
+	return ctx, nil
+}
+
+func (m *TimestampMod) AfterMutation(ctx context.Context, db bun.IDB, event *MutationEvent, res sql.Result) error {
+	return nil
+}
+
+func setTimeValue(fv reflect.Value, tm time.Time) error {
+	switch v := fv.Addr().Interface().(type) {
+	case *time.Time:
+		*v = tm
+	case **time.Time:
+		*v = &tm
+	case *bun.NullTime:
+		*v = bun.NullTime{Time: tm}
+	case *sql.NullTime:
+		*v = sql.NullTime{Time: tm, Valid: true}
+	default:
+		return fmt.Errorf("unsupported timestamp type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package bunquery_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmorton/bunquery"
+)
+
+type stampedItem struct {
+	ID        int64 `bun:",pk,autoincrement"`
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func TestTimestampMod(t *testing.T) {
+	db := newSQLiteDB(t, (*stampedItem)(nil))
+	ctx := bunquery.NewContext(context.Background(), db, bunquery.NewTimestampMod())
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	at := func(d int) context.Context {
+		return bunquery.NewClockContext(ctx, func() time.Time { return day(d) })
+	}
+	mutate := func(ctx context.Context, fn func(ctx context.Context, db bunquery.MutationDB) error) {
+		assert.NoError(t, bunquery.UseMutation(ctx, fn))
+	}
+	stored := func(id int64) time.Time {
+		var item stampedItem
+		assert.NoError(t, db.NewSelect().Model(&item).Where("id = ?", id).Scan(ctx))
+		return item.UpdatedAt.UTC()
+	}
+
+	a, b := &stampedItem{Title: "a"}, &stampedItem{Title: "b"}
+	mutate(at(1), func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewInsert().Model(&[]*stampedItem{a, b}).Exec(ctx)
+		return err
+	})
+	assert.Equal(t, day(1), a.CreatedAt)
+	assert.Equal(t, day(1), stored(a.ID))
+
+	// Inserts with column lists get the timestamps added.
+	c := &stampedItem{Title: "c"}
+	mutate(at(1), func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewInsert().Model(c).Column("title").Exec(ctx)
+		return err
+	})
+	var item stampedItem
+	assert.NoError(t, db.NewSelect().Model(&item).Where("title = ?", "c").Scan(ctx))
+	assert.Equal(t, day(1), item.CreatedAt.UTC())
+	assert.Equal(t, day(1), item.UpdatedAt.UTC())
+
+	// Column lists and SET clauses get the timestamp added.
+	mutate(at(2), func(ctx context.Context, db bunquery.MutationDB) error {
+		a.Title = "a2"
+		_, err := db.NewUpdate().Model(a).Column("title").WherePK().Exec(ctx)
+		return err
+	})
+	assert.Equal(t, day(2), a.UpdatedAt)
+	assert.Equal(t, day(2), stored(a.ID))
+
+	mutate(at(3), func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model(a).Set("title = ?", "a3").WherePK().Exec(ctx)
+		return err
+	})
+	assert.Equal(t, day(3), stored(a.ID))
+
+	// So do updates by filter.
+	mutate(at(4), func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model((*stampedItem)(nil)).Set("title = title || ?", "!").Where("id > 0").Exec(ctx)
+		return err
+	})
+	assert.Equal(t, day(4), stored(a.ID))
+	assert.Equal(t, day(4), stored(b.ID))
+
+	// A timestamp set by the statement is left as it is.
+	mutate(at(5), func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model((*stampedItem)(nil)).Set("updated_at = ?", day(9)).Where("id = ?", b.ID).Exec(ctx)
+		return err
+	})
+	assert.Equal(t, day(9), stored(b.ID))
+}
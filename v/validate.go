@@ -94,6 +94,47 @@ func Struct[Source any, Value any](grp *Set[Source], get func(Source) Value, nex
 	next(nextV)
 }
 
+// Optional runs the validations of next on an optional value, when get reports there
+// is one. It fits the Get method of bunquery.Optional, which reports no value for null,
+// so use OptionalNotNull where null is not valid.
+func Optional[Source any, Value any](grp *Set[Source], get func(Source) (Value, bool), next func(*Set[Value])) {
+	nextV := &Set[Value]{}
+	grp.validations = append(grp.validations, func(source Source) error {
+		value, ok := get(source)
+		if !ok {
+			return nil
+		}
+		errs := make([]error, 0, len(nextV.validations))
+		for _, check := range nextV.validations {
+			if err := check(value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		return nil
+	})
+	next(nextV)
+}
+
+// Nullable is an optional value that may also be null, such as bunquery.Optional.
+type Nullable[Value any] interface {
+	Get() (Value, bool)
+	IsNull() bool
+}
+
+// OptionalNotNull is Optional for values that may be null, and fails when they are.
+func OptionalNotNull[Source any, Value any, Opt Nullable[Value]](grp *Set[Source], get func(Source) Opt, next func(*Set[Value])) {
+	grp.validations = append(grp.validations, func(source Source) error {
+		if get(source).IsNull() {
+			return errors.New("value is null")
+		}
+		return nil
+	})
+	Optional(grp, func(source Source) (Value, bool) { return get(source).Get() }, next)
+}
+
 func Args[Value any](fn func(validator *Set[Value])) func(Value) (Value, error) {
 	var zed Value
 	validator := &Set[Value]{}
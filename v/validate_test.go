@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/mmorton/bunquery"
 )
 
 func TestBasicValidation(t *testing.T) {
@@ -108,3 +110,53 @@ func TestStringLengthValidation(t *testing.T) {
 	_, err = check(&GetValueArgs{Value: "hello"})
 	assert.NoError(t, err)
 }
+
+func TestOptionalValidation(t *testing.T) {
+	type PatchArgs struct {
+		Name bunquery.Optional[string]
+	}
+
+	check := Args(func(set *Set[*PatchArgs]) {
+		Optional(set, func(args *PatchArgs) (string, bool) { return args.Name.Get() }, func(set *Set[string]) {
+			String(set, func(name string) string { return name }).Min(1)
+		})
+	})
+
+	var err error
+	_, err = check(&PatchArgs{})
+	assert.NoError(t, err)
+
+	_, err = check(&PatchArgs{Name: bunquery.Null[string]()})
+	assert.NoError(t, err)
+
+	_, err = check(&PatchArgs{Name: bunquery.Some("a")})
+	assert.NoError(t, err)
+
+	_, err = check(&PatchArgs{Name: bunquery.Some("")})
+	assert.Error(t, err)
+}
+
+func TestOptionalNotNullValidation(t *testing.T) {
+	type PatchArgs struct {
+		Name bunquery.Optional[string]
+	}
+
+	check := Args(func(set *Set[*PatchArgs]) {
+		OptionalNotNull(set, func(args *PatchArgs) bunquery.Optional[string] { return args.Name }, func(set *Set[string]) {
+			String(set, func(name string) string { return name }).Min(1)
+		})
+	})
+
+	var err error
+	_, err = check(&PatchArgs{})
+	assert.NoError(t, err)
+
+	_, err = check(&PatchArgs{Name: bunquery.Some("a")})
+	assert.NoError(t, err)
+
+	_, err = check(&PatchArgs{Name: bunquery.Null[string]()})
+	assert.Error(t, err)
+
+	_, err = check(&PatchArgs{Name: bunquery.Some("")})
+	assert.Error(t, err)
+}
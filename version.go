@@ -0,0 +1,209 @@
+package bunquery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+type ErrStaleObject struct {
+	Model any
+	PK    map[string]any
+}
+
+func (e *ErrStaleObject) Error() string {
+	return fmt.Sprintf("stale object %T %v: row was changed or deleted concurrently", e.Model, e.PK)
+}
+
+type VersionOptions struct {
+	Column string
+}
+
+type VersionOption = func(*VersionOptions)
+
+func WithVersionColumn(col string) VersionOption {
+	return func(opts *VersionOptions) {
+		opts.Column = col
+	}
+}
+
+// VersionMod adds optimistic concurrency control to updates and deletes of a single
+// model that has a version column, either named by the options or tagged `bun:",version"`.
+// Those fail with ErrStaleObject when the row is not at the version of the model, and
+// updates increment the version of the row and then of the model. Updates and deletes
+// of many models fail, as their versions can't be checked by one statement. Updates by
+// filter only increment the version of the rows, and deletes by filter aren't checked.
+//
+// Statements that return rows, such as updates with RETURNING, are checked once their
+// rows are read: before the next statement of the mutation, or when it ends. Their
+// rows must be scanned into the model rather than a destination given to Exec.
+type VersionMod struct {
+	opts *VersionOptions
+}
+
+var _ QueryMod = (*VersionMod)(nil)
+var _ MutationHook = (*VersionMod)(nil)
+
+func NewVersionMod(opts ...VersionOption) *VersionMod {
+	res := &VersionOptions{Column: "version"}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return &VersionMod{opts: res}
+}
+
+func (m *VersionMod) Kind() string { return "version" }
+
+func (m *VersionMod) Bind(ctx context.Context, db bun.IDB, qry QueryBuilderEx, args ...any) {}
+
+func (m *VersionMod) versionField(table *schema.Table) *schema.Field {
+	for _, field := range table.Fields {
+		if field.Tag.HasOption("version") {
+			return field
+		}
+	}
+	return table.FieldMap[m.opts.Column]
+}
+
+type versionCtxKey struct{}
+
+type versionState struct {
+	current int64
+	bump    bool // the statement increments the version
+}
+
+func (m *VersionMod) BeforeMutation(ctx context.Context, db bun.IDB, event *MutationEvent) (context.Context, error) {
+	if event.Table == nil {
+		return ctx, nil
+	}
+	field := m.versionField(event.Table)
+	if field == nil {
+		return ctx, nil
+	}
+
+	qry, ok := event.Query.(*bun.UpdateQuery)
+	var writes *queryWrites
+	if ok {
+		writes = getQueryWrites(qry, event.Table)
+	}
+
+	models := event.Models()
+	if kind := event.Kind(); len(models) > 1 && (kind == QueryUpdate || kind == QueryDelete) {
+		return ctx, fmt.Errorf("version: can't check the versions of %d %s models in one %s, change them one at a time", len(models), event.Table.TypeName, kind)
+	} else if len(models) != 1 {
+		if writes != nil && !writes.has(field.Name) {
+			qry.Set("? = ? + 1", bun.Ident(field.Name), bun.Ident(field.Name))
+			event.Rebuild()
+		}
+		return ctx, nil
+	}
+
+	current, err := getVersionValue(field.Value(models[0]))
+	if err != nil {
+		return ctx, fmt.Errorf("%s.%s: %w", event.Table.TypeName, field.GoName, err)
+	}
+	state := &versionState{current: current}
+
+	switch qry := event.Query.(type) {
+	case *bun.UpdateQuery:
+		qry.Where("?TableAlias.? = ?", bun.Safe(field.SQLName), current)
+		switch {
+		case slices.Contains(writes.set, field.Name):
+			// The statement sets the version itself.
+		case writes.hasField(field.Name):
+			qry.Value(field.Name, "? + 1", bun.Ident(field.Name))
+			state.bump = true
+		default:
+			// Column lists and SET clauses don't write the version from the model.
+			qry.Set("? = ? + 1", bun.Ident(field.Name), bun.Ident(field.Name))
+			if event.Changes != nil {
+				event.Changes[field.Name] = current + 1
+			}
+			state.bump = true
+		}
+		if returning, _ := getQueryField[[]schema.QueryWithArgs](qry, "returning"); state.bump && len(returning) > 0 &&
+			!slices.ContainsFunc(returning, func(ret schema.QueryWithArgs) bool {
+				return ret.Args == nil && (ret.Query == "*" || ret.Query == field.Name)
+			}) {
+			// The returned version tells whether the row was updated.
+			qry.Returning("?", bun.Ident(field.Name))
+		}
+	case *bun.DeleteQuery:
+		qry.Where("?TableAlias.? = ?", bun.Safe(field.SQLName), current)
+	default:
+		return ctx, nil
+	}
+
+	event.Rebuild()
+	return context.WithValue(ctx, versionCtxKey{}, state), nil
+}
+
+func (m *VersionMod) AfterMutation(ctx context.Context, db bun.IDB, event *MutationEvent, res sql.Result) error {
+	state, ok := ctx.Value(versionCtxKey{}).(*versionState)
+	if !ok {
+		return nil
+	}
+	current := state.current
+	field := m.versionField(event.Table)
+	strct := event.Models()[0]
+
+	var found bool
+	if res != nil {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		found = n > 0
+	} else if event.Kind() == QueryUpdate && state.bump {
+		// Rows were returned instead of a result, and the row was only returned into
+		// the model when it was updated.
+		v, err := getVersionValue(field.Value(strct))
+		if err != nil {
+			return err
+		}
+		found = v == current+1
+	} else {
+		return nil
+	}
+
+	if !found {
+		pk := make(map[string]any, len(event.Table.PKs))
+		for _, f := range event.Table.PKs {
+			pk[f.Name] = f.Value(strct).Interface()
+		}
+		return &ErrStaleObject{Model: strct.Addr().Interface(), PK: pk}
+	}
+
+	if event.Kind() == QueryUpdate && state.bump {
+		// RETURNING may already have scanned the new version into the model.
+		if v, _ := getVersionValue(field.Value(strct)); v == current {
+			setVersionValue(field.Value(strct), current+1)
+		}
+	}
+	return nil
+}
+
+func getVersionValue(fv reflect.Value) (int64, error) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(fv.Uint()), nil
+	default:
+		return 0, fmt.Errorf("unsupported version type %s", fv.Type())
+	}
+}
+
+func setVersionValue(fv reflect.Value, v int64) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fv.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fv.SetUint(uint64(v))
+	}
+}
@@ -0,0 +1,116 @@
+package bunquery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmorton/bunquery"
+)
+
+type versionedItem struct {
+	ID      int64 `bun:",pk,autoincrement"`
+	Title   string
+	Version int64
+}
+
+func TestVersionMod(t *testing.T) {
+	db := newSQLiteDB(t, (*versionedItem)(nil))
+	ctx := bunquery.NewContext(context.Background(), db, bunquery.NewVersionMod())
+	mutate := func(fn func(ctx context.Context, db bunquery.MutationDB) error) error {
+		return bunquery.UseMutation(ctx, fn)
+	}
+	stored := func(id int64) int64 {
+		var item versionedItem
+		assert.NoError(t, db.NewSelect().Model(&item).Where("id = ?", id).Scan(ctx))
+		return item.Version
+	}
+
+	item, other := &versionedItem{Title: "a"}, &versionedItem{Title: "b"}
+	assert.NoError(t, mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewInsert().Model(&[]*versionedItem{item, other}).Exec(ctx)
+		return err
+	}))
+
+	// Full updates, column lists and SET clauses all increment the row and the model.
+	assert.NoError(t, mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		if _, err := db.NewUpdate().Model(item).WherePK().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewUpdate().Model(item).Column("title").WherePK().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewUpdate().Model(item).Set("title = ?", "set").WherePK().Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, int64(3), item.Version)
+	assert.Equal(t, int64(3), stored(item.ID))
+
+	// A stale model fails and keeps its version.
+	stale := *item
+	stale.Version = 1
+	err := mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model(&stale).Column("title").WherePK().Exec(ctx)
+		return err
+	})
+	var staleErr *bunquery.ErrStaleObject
+	assert.ErrorAs(t, err, &staleErr)
+	assert.Equal(t, int64(1), stale.Version)
+
+	// With RETURNING, the update is checked before the next statement.
+	err = mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		if _, err := db.NewUpdate().Model(&stale).WherePK().Returning("*").Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewUpdate().Model(other).WherePK().Exec(ctx)
+		return err
+	})
+	assert.ErrorAs(t, err, &staleErr)
+	assert.Equal(t, int64(0), stored(other.ID))
+
+	assert.NoError(t, mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model(item).WherePK().Returning("*").Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, int64(4), item.Version)
+	assert.Equal(t, int64(4), stored(item.ID))
+
+	// A model one version behind is stale too, whatever RETURNING lists.
+	behind := *item
+	behind.Version = 3
+	err = mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model(&behind).Column("title").WherePK().Returning("title").Exec(ctx)
+		return err
+	})
+	assert.ErrorAs(t, err, &staleErr)
+	assert.Equal(t, int64(3), behind.Version)
+
+	assert.NoError(t, mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model(item).Column("title").WherePK().Returning("title").Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, int64(5), item.Version)
+	assert.Equal(t, int64(5), stored(item.ID))
+
+	// Updates by filter increment every row they change.
+	assert.NoError(t, mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewUpdate().Model((*versionedItem)(nil)).Set("title = ?", "all").Where("id > 0").Exec(ctx)
+		return err
+	}))
+	assert.Equal(t, int64(6), stored(item.ID))
+	assert.Equal(t, int64(1), stored(other.ID))
+
+	// Many models can't be checked by one statement.
+	err = mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewDelete().Model(&[]*versionedItem{item, other}).WherePK().Exec(ctx)
+		return err
+	})
+	assert.ErrorContains(t, err, "can't check the versions of 2 VersionedItem models in one delete")
+
+	// Deletes by filter aren't checked.
+	assert.NoError(t, mutate(func(ctx context.Context, db bunquery.MutationDB) error {
+		_, err := db.NewDelete().Model((*versionedItem)(nil)).Where("id = ?", other.ID).Exec(ctx)
+		return err
+	}))
+}